@@ -4,5 +4,28 @@ set -exuo pipefail
 cd "$( dirname "${BASH_SOURCE[0]}" )/.."
 source .envrc
 
-go build -ldflags="-s -w" -o bin/supply go/supply/cli
-go build -ldflags="-s -w" -o bin/finalize go/finalize/cli
+# The packaged buildpack runs these binaries directly, so build one per cell
+# architecture and replace bin/supply and bin/finalize with scripts that pick
+# the one for the cell's.
+for phase in supply finalize; do
+  for arch in amd64 arm64; do
+    GOOS=linux GOARCH=$arch go build -ldflags="-s -w" -o bin/$phase-linux-$arch go/$phase/cli
+  done
+
+  cat > bin/$phase <<SCRIPT
+#!/bin/bash
+set -euo pipefail
+
+case "\$(uname -m)" in
+  x86_64 | amd64) arch=amd64 ;;
+  aarch64 | arm64) arch=arm64 ;;
+  *)
+    echo "       **ERROR** Unsupported architecture \$(uname -m)"
+    exit 1
+    ;;
+esac
+
+exec "\$(dirname "\${BASH_SOURCE[0]}")/$phase-linux-\$arch" "\$@"
+SCRIPT
+  chmod +x bin/$phase
+done
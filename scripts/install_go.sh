@@ -1,22 +1,84 @@
 #!/bin/bash
 set -euo pipefail
 
+source "$BUILDPACK_DIR/scripts/log.sh"
+
 GO_VERSION="1.9.1"
 
 export GoInstallDir="/tmp/go$GO_VERSION"
 mkdir -p $GoInstallDir
 
 if [ ! -f $GoInstallDir/go/bin/go ]; then
-  GO_MD5="0571886e9b9ba07773b542a11e9859a4"
-  URL=https://buildpacks.cloudfoundry.org/dependencies/go/go${GO_VERSION}.linux-amd64-${GO_MD5:0:8}.tar.gz
+  case "$(uname -m)" in
+    x86_64 | amd64) GO_ARCH=amd64 ;;
+    aarch64 | arm64) GO_ARCH=arm64 ;;
+    *)
+      bp_log_error "Unsupported architecture $(uname -m)"
+      exit 1
+      ;;
+  esac
+
+  # The bootstrap toolchain is the manifest's go $GO_VERSION entry for this
+  # architecture, so arm64 cells work once the manifest publishes one.
+  read -r GO_URI GO_SHA256 < <(awk -v version="$GO_VERSION" -v arch="linux-$GO_ARCH" '
+    function check() {
+      if (name == "go" && ver == version && index(uri, arch) && sha != "") { print uri, sha; found = 1; exit }
+    }
+    /^- / || /^[^ -]/ { check(); name = ver = uri = sha = "" }
+    { sub(/^- /, "  ") }
+    /^  name:/ { name = $2 }
+    /^  version:/ { ver = $2 }
+    /^  uri:/ { uri = $2 }
+    /^  sha256:/ { sha = $2 }
+    END { if (!found) check() }
+  ' "$BUILDPACK_DIR/manifest.yml") || true
+  if [ -z "${GO_URI:-}" ]; then
+    bp_log_error "manifest.yml has no go ${GO_VERSION} for linux-${GO_ARCH} to build the buildpack with"
+    exit 1
+  fi
 
-  echo "-----> Download go ${GO_VERSION}"
-  curl -s -L --retry 15 --retry-delay 2 $URL -o /tmp/go.tar.gz
+  GO_PATH=/${GO_URI#*://*/}
+  URL=$GO_URI
+
+  MIRROR=${BP_DEPENDENCY_MIRROR:-}
+  if [ -z "$MIRROR" ] && [ -f "$BUILDPACK_DIR/dependency-mirror" ]; then
+    MIRROR=$(grep -v '^[[:space:]]*\(#\|$\)' "$BUILDPACK_DIR/dependency-mirror" | head -n 1 | tr -d '[:space:]' || true)
+  fi
+  if [ -n "$MIRROR" ]; then
+    URL=${MIRROR%/}$GO_PATH
+    bp_log_step download_go "Download go ${GO_VERSION} from dependency mirror"
+  else
+    bp_log_step download_go "Download go ${GO_VERSION}"
+  fi
 
-  DOWNLOAD_MD5=$(md5sum /tmp/go.tar.gz | cut -d ' ' -f 1)
+  if ! curl -s -f -L --retry 15 --retry-delay 2 $URL -o /tmp/go.tar.gz; then
+    if [ -n "$MIRROR" ]; then
+      bp_log_error "Could not download go from dependency mirror $(echo "$MIRROR" | sed 's#//[^/@]*@#//#')"
+    else
+      bp_log_error "Could not download go"
+    fi
+    exit 1
+  fi
+
+  DOWNLOAD_SHA256=$(sha256sum /tmp/go.tar.gz | cut -d ' ' -f 1)
+
+  if [[ $DOWNLOAD_SHA256 != $GO_SHA256 ]]; then
+    bp_log_error "SHA256 mismatch: got $DOWNLOAD_SHA256 expected $GO_SHA256"
+    exit 1
+  fi
 
-  if [[ $DOWNLOAD_MD5 != $GO_MD5 ]]; then
-    echo "       **ERROR** MD5 mismatch: got $DOWNLOAD_MD5 expected $GO_MD5"
+  KEYRING=$BUILDPACK_DIR/signing/go-keyring.gpg
+  SIGNATURE=$BUILDPACK_DIR/signing/go${GO_VERSION}.linux-${GO_ARCH}.tar.gz.asc
+  if [ -f "$KEYRING" ] && [ -f "$SIGNATURE" ]; then
+    if ! gpgv --keyring "$KEYRING" "$SIGNATURE" /tmp/go.tar.gz > /dev/null 2>&1; then
+      if [ "${GO_REQUIRE_SIGNED_TOOLCHAIN:-}" == "true" ]; then
+        bp_log_error "Signature of go ${GO_VERSION} does not verify against $KEYRING"
+        exit 1
+      fi
+      bp_log_warning "Signature of go ${GO_VERSION} does not verify against $KEYRING"
+    fi
+  elif [ "${GO_REQUIRE_SIGNED_TOOLCHAIN:-}" == "true" ]; then
+    bp_log_error "\$GO_REQUIRE_SIGNED_TOOLCHAIN is set, but the buildpack has no keyring or signature for go ${GO_VERSION}"
     exit 1
   fi
 
@@ -24,7 +86,7 @@ if [ ! -f $GoInstallDir/go/bin/go ]; then
   rm /tmp/go.tar.gz
 fi
 if [ ! -f $GoInstallDir/go/bin/go ]; then
-  echo "       **ERROR** Could not download go"
+  bp_log_error "Could not download go"
   exit 1
 fi
 
@@ -0,0 +1,70 @@
+# Logging for the scripts that bootstrap the buildpack before the Go logger
+# takes over. Output matches libbuildpack's format, or the JSON lines of
+# go/eventlog when $BP_LOG_FORMAT is json, so that stdout stays one stream
+# the pipeline can parse.
+
+BP_LOG_STEP=""
+BP_LOG_STEP_START=0
+
+bp_log_json() {
+  [ "${BP_LOG_FORMAT:-}" == "json" ]
+}
+
+bp_log_now_ms() {
+  date +%s%3N
+}
+
+bp_log_emit() {
+  local type=$1 code=$2 message=$3 duration_ms=$4
+  message=${message//\\/\\\\}
+  message=${message//\"/\\\"}
+  if [ -n "$message" ]; then
+    message=",\"message\":\"$message\""
+  fi
+  printf '{"time":"%s","type":"%s","code":"%s"%s,"duration_ms":%d}\n' \
+    "$(date -u +%Y-%m-%dT%H:%M:%S.%3NZ)" "$type" "$code" "$message" "$duration_ms"
+}
+
+bp_log_since_step() {
+  if [ -n "$BP_LOG_STEP" ]; then
+    echo $(($(bp_log_now_ms) - BP_LOG_STEP_START))
+  else
+    echo 0
+  fi
+}
+
+# bp_log_step CODE MESSAGE announces a step, ending the previous one.
+bp_log_step() {
+  if ! bp_log_json; then
+    echo "-----> $2"
+    return
+  fi
+  bp_log_end_step
+  BP_LOG_STEP=$1
+  BP_LOG_STEP_START=$(bp_log_now_ms)
+  bp_log_emit step "$1" "$2" 0
+}
+
+# bp_log_end_step ends the current step, before the Go phase starts its own.
+bp_log_end_step() {
+  if bp_log_json && [ -n "$BP_LOG_STEP" ]; then
+    bp_log_emit step_end "$BP_LOG_STEP" "" "$(bp_log_since_step)"
+    BP_LOG_STEP=""
+  fi
+}
+
+bp_log_error() {
+  if bp_log_json; then
+    bp_log_emit error "${BP_LOG_STEP:-bootstrap}.error" "$1" "$(bp_log_since_step)"
+  else
+    echo "       **ERROR** $1"
+  fi
+}
+
+bp_log_warning() {
+  if bp_log_json; then
+    bp_log_emit warning "${BP_LOG_STEP:-bootstrap}.warning" "$1" "$(bp_log_since_step)"
+  else
+    echo "       **WARNING** $1"
+  fi
+}
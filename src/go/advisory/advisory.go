@@ -0,0 +1,249 @@
+package advisory
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/lockfile"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Severity levels, in increasing order. Advisories that carry neither a
+// severity name nor a CVSS v3 vector rate as Unknown.
+const (
+	Unknown = iota
+	Low
+	Moderate
+	High
+	Critical
+)
+
+var severityNames = []string{"UNKNOWN", "LOW", "MODERATE", "HIGH", "CRITICAL"}
+
+// Entry is the part of an OSV (https://ossf.github.io/osv-schema/) record
+// needed to match Go dependencies.
+type Entry struct {
+	ID       string     `json:"id"`
+	Summary  string     `json:"summary"`
+	Affected []Affected `json:"affected"`
+	Severity []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// severity prefers the database's own severity name, then the worst rating
+// of the entry's CVSS v3 vectors.
+func (e Entry) severity() int {
+	if severity, err := ParseSeverity(e.DatabaseSpecific.Severity); err == nil && severity != Unknown {
+		return severity
+	}
+
+	severity := Unknown
+	for _, s := range e.Severity {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		if rating := cvss3Severity(s.Score); rating > severity {
+			severity = rating
+		}
+	}
+	return severity
+}
+
+type Affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string              `json:"type"`
+		Events []map[string]string `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+type Finding struct {
+	ID       string
+	Summary  string
+	Package  string
+	Version  string
+	Fixed    string
+	Severity int
+}
+
+func (f Finding) SeverityName() string {
+	return severityNames[f.Severity]
+}
+
+// ParseSeverity accepts the severity names used by OSV databases
+// ("MEDIUM" is an alias for "MODERATE"), in any case.
+func ParseSeverity(name string) (int, error) {
+	name = strings.ToUpper(name)
+	if name == "MEDIUM" {
+		name = "MODERATE"
+	}
+	for level, severity := range severityNames {
+		if severity == name {
+			return level, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown severity %q", name)
+}
+
+// Load reads an OSV database from a single JSON file (one record or an array
+// of records) or from a directory of such files.
+func Load(path string) ([]Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return loadFile(path)
+	}
+
+	var entries []Entry
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(file) != ".json" {
+			return nil
+		}
+
+		fileEntries, err := loadFile(file)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+		return nil
+	})
+	return entries, err
+}
+
+func loadFile(file string) ([]Entry, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if strings.HasPrefix(strings.TrimSpace(string(contents)), "[") {
+		err = json.Unmarshal(contents, &entries)
+	} else {
+		var entry Entry
+		err = json.Unmarshal(contents, &entry)
+		entries = append(entries, entry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return entries, nil
+}
+
+// Check matches dependencies, and the Go toolchain as the "stdlib" package,
+// against the database. Dependencies pinned only to a revision can't be
+// placed in a semver range and are matched against explicit versions only.
+func Check(entries []Entry, goVersion string, deps []lockfile.Dependency) []Finding {
+	deps = append([]lockfile.Dependency{{Name: "stdlib", Version: "v" + goVersion}}, deps...)
+
+	var findings []Finding
+	for _, entry := range entries {
+		severity := entry.severity()
+
+		for _, affected := range entry.Affected {
+			if !strings.EqualFold(affected.Package.Ecosystem, "Go") {
+				continue
+			}
+
+			for _, dep := range deps {
+				if dep.Name != affected.Package.Name {
+					continue
+				}
+
+				version := dep.Version
+				if version == "" {
+					version = dep.Revision
+				}
+
+				if vulnerable, fixed := affected.matches(version); vulnerable {
+					findings = append(findings, Finding{
+						ID:       entry.ID,
+						Summary:  entry.Summary,
+						Package:  dep.Name,
+						Version:  version,
+						Fixed:    fixed,
+						Severity: severity,
+					})
+				}
+			}
+		}
+	}
+
+	return findings
+}
+
+func (a Affected) matches(version string) (bool, string) {
+	for _, v := range a.Versions {
+		if strings.TrimPrefix(v, "v") == strings.TrimPrefix(version, "v") {
+			return true, ""
+		}
+	}
+
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return false, ""
+	}
+
+	for _, r := range a.Ranges {
+		if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+			continue
+		}
+
+		affected := false
+		for _, event := range r.Events {
+			if introduced, ok := event["introduced"]; ok && !parsed.LessThan(eventVersion(introduced)) {
+				affected = true
+			}
+			if fixed, ok := event["fixed"]; ok && !parsed.LessThan(eventVersion(fixed)) {
+				affected = false
+			}
+			if last, ok := event["last_affected"]; ok && parsed.GreaterThan(eventVersion(last)) {
+				affected = false
+			}
+		}
+		if affected {
+			return true, fixedVersion(r.Events, parsed)
+		}
+	}
+
+	return false, ""
+}
+
+func fixedVersion(events []map[string]string, version *semver.Version) string {
+	for _, event := range events {
+		if fixed, ok := event["fixed"]; ok && version.LessThan(eventVersion(fixed)) {
+			return fixed
+		}
+	}
+	return ""
+}
+
+func eventVersion(version string) *semver.Version {
+	if version == "0" {
+		version = "0.0.0"
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return semver.MustParse("0.0.0")
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+package advisory
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the CVSS v3.x base metric weights
+// (https://www.first.org/cvss/v3.1/specification-document). Privileges
+// required weigh more when the scope changes, hence the "PR:C" entries.
+var cvss3Weights = map[string]map[string]float64{
+	"AV":   {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC":   {"L": 0.77, "H": 0.44},
+	"PR":   {"N": 0.85, "L": 0.62, "H": 0.27},
+	"PR:C": {"N": 0.85, "L": 0.68, "H": 0.5},
+	"UI":   {"N": 0.85, "R": 0.62},
+	"C":    {"H": 0.56, "L": 0.22, "N": 0},
+	"I":    {"H": 0.56, "L": 0.22, "N": 0},
+	"A":    {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3Severity rates a CVSS v3.x vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H" by its base score, or
+// returns Unknown if the vector lacks a base metric.
+func cvss3Severity(vector string) int {
+	if !strings.HasPrefix(vector, "CVSS:3.") {
+		return Unknown
+	}
+
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/")[1:] {
+		if kv := strings.SplitN(part, ":", 2); len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return Unknown
+	}
+
+	weight := func(metric string) (float64, bool) {
+		table := metric
+		if metric == "PR" && changed {
+			table = "PR:C"
+		}
+		w, ok := cvss3Weights[table][metrics[metric]]
+		return w, ok
+	}
+
+	w := map[string]float64{}
+	for _, metric := range []string{"AV", "AC", "PR", "UI", "C", "I", "A"} {
+		value, ok := weight(metric)
+		if !ok {
+			return Unknown
+		}
+		w[metric] = value
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+
+	score := 0.0
+	if impact > 0 {
+		if changed {
+			score = roundUp(math.Min(1.08*(impact+exploitability), 10))
+		} else {
+			score = roundUp(math.Min(impact+exploitability, 10))
+		}
+	}
+
+	switch {
+	case score >= 9:
+		return Critical
+	case score >= 7:
+		return High
+	case score >= 4:
+		return Moderate
+	default:
+		return Low
+	}
+}
+
+// roundUp is the CVSS v3.1 Roundup: the smallest one-decimal number not
+// below x, computed on integers to avoid floating point surprises.
+func roundUp(x float64) float64 {
+	i := int(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
@@ -3,6 +3,8 @@ package data
 import (
 	"fmt"
 	"path"
+	"sort"
+	"strings"
 )
 
 func ReleaseYAML(mainPackageName string) string {
@@ -17,6 +19,50 @@ func GoScript() string {
 	return "PATH=$PATH:$HOME/bin\n"
 }
 
+// GoRuntimeScript sizes the Go runtime to the container at launch. Values the
+// user set are left alone. GOMEMLIMIT needs go1.19; older toolchains get a
+// lower GOGC in small containers instead.
+func GoRuntimeScript(hasMemLimit bool) string {
+	contents := `if [ -z "${GOMAXPROCS:-}" ]; then
+  quota=""
+  period=""
+  if [ -f /sys/fs/cgroup/cpu.max ]; then
+    read -r quota period < /sys/fs/cgroup/cpu.max
+  elif [ -f /sys/fs/cgroup/cpu/cpu.cfs_quota_us ]; then
+    quota=$(cat /sys/fs/cgroup/cpu/cpu.cfs_quota_us)
+    period=$(cat /sys/fs/cgroup/cpu/cpu.cfs_period_us)
+  fi
+  if [ -n "$quota" ] && [ "$quota" != "max" ] && [ "$quota" -gt 0 ] 2>/dev/null && [ "$period" -gt 0 ] 2>/dev/null; then
+    export GOMAXPROCS=$(( (quota + period - 1) / period ))
+  fi
+fi
+
+memory_limit_mb=""
+case "${MEMORY_LIMIT:-}" in
+  *[gG]) memory_limit_mb=$(( ${MEMORY_LIMIT%%[gG]} * 1024 )) ;;
+  *[mM]) memory_limit_mb=${MEMORY_LIMIT%%[mM]} ;;
+  *[kK]) memory_limit_mb=$(( ${MEMORY_LIMIT%%[kK]} / 1024 )) ;;
+esac
+%s
+unset quota period memory_limit_mb
+`
+
+	memory := `
+if [ -n "$memory_limit_mb" ] && [ -z "${GOMEMLIMIT:-}" ]; then
+  export GOMEMLIMIT=$(( memory_limit_mb * 9 / 10 ))MiB
+fi
+`
+	if !hasMemLimit {
+		memory = `
+if [ -n "$memory_limit_mb" ] && [ -z "${GOGC:-}" ] && [ "$memory_limit_mb" -le 512 ]; then
+  export GOGC=50
+fi
+`
+	}
+
+	return fmt.Sprintf(contents, memory)
+}
+
 func GoRootScript(goRoot string) string {
 	contents := `export GOROOT=%s
 PATH=$PATH:$GOROOT/bin
@@ -25,9 +71,45 @@ PATH=$PATH:$GOROOT/bin
 	return fmt.Sprintf(contents, goRoot)
 }
 
-func ZZGoPathScript(mainPackageName string) string {
+func ZZGoPathScript(importPath string) string {
 	contents := `export GOPATH=$HOME
 cd $GOPATH/src/%s
 `
-	return fmt.Sprintf(contents, path.Base(mainPackageName))
+	return fmt.Sprintf(contents, importPath)
+}
+
+func BuildReportScript(reportFile string) string {
+	return fmt.Sprintf("export GO_BUILD_REPORT=$HOME/%s\n", reportFile)
+}
+
+// ProcessSettingsScript exports the runtime settings for the process type
+// being launched, which Cloud Foundry records in $VCAP_APPLICATION. Values
+// set with cf set-env take precedence.
+func ProcessSettingsScript(settings map[string]map[string]string) string {
+	var processes []string
+	for process := range settings {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+
+	script := `process_type=$(echo "${VCAP_APPLICATION:-}" | sed -n 's/.*"process_type": *"\([^"]*\)".*/\1/p')
+case "$process_type" in
+`
+	for _, process := range processes {
+		var names []string
+		for name := range settings[process] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		script += fmt.Sprintf("  %s)\n", process)
+		for _, name := range names {
+			value := strings.Replace(settings[process][name], "'", `'\''`, -1)
+			script += fmt.Sprintf("    [ -z \"${%s:-}\" ] && export %s='%s'\n", name, name, value)
+		}
+		script += "    ;;\n"
+	}
+	script += "esac\nunset process_type\n"
+
+	return script
 }
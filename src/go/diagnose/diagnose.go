@@ -0,0 +1,125 @@
+package diagnose
+
+import (
+	"go/warnings"
+	"regexp"
+	"sort"
+
+	"github.com/Masterminds/semver"
+)
+
+var (
+	missingPackage = []*regexp.Regexp{
+		regexp.MustCompile(`cannot find package "([^"]+)"`),
+		regexp.MustCompile(`no required module provides package (\S+?);`),
+		regexp.MustCompile(`package (\S+) is not in GOROOT`),
+	}
+	importCycle = regexp.MustCompile(`(?m)^(?:\S.*)?import cycle not allowed\s*\n((?:\s*(?:package|imports) \S+\s*\n?)+)`)
+	cycleStep   = regexp.MustCompile(`(?:package|imports) (\S+)`)
+	requiresGo  = []*regexp.Regexp{
+		regexp.MustCompile(`note: module requires Go (\d+\.\d+(?:\.\d+)?)`),
+		regexp.MustCompile(`requires go >= (\d+\.\d+(?:\.\d+)?)`),
+	}
+	undefined     = regexp.MustCompile(`undefined: ([A-Za-z_][\w.]*)`)
+	typeAlias     = regexp.MustCompile(`syntax error: unexpected = in type declaration`)
+	typeParams    = regexp.MustCompile(`syntax error: unexpected \[`)
+	privateRepo   = regexp.MustCompile(`(?i)(terminal prompts disabled|could not read Username for '([^']+)'|Permission denied \(publickey\)|Authentication failed for '([^']+)'|remote: Repository not found)`)
+	repoHostInURL = regexp.MustCompile(`(?:https?://|git@)([^/:']+)`)
+)
+
+// sinceVersion maps standard library identifiers that old toolchains
+// report as undefined to the Go version that added them.
+var sinceVersion = map[string]string{
+	"sort.Slice":       "1.8",
+	"sort.SliceStable": "1.8",
+	"strings.Builder":  "1.10",
+	"errors.Is":        "1.13",
+	"errors.As":        "1.13",
+	"errors.Unwrap":    "1.13",
+	"os.ReadFile":      "1.16",
+	"os.WriteFile":     "1.16",
+	"os.ReadDir":       "1.16",
+	"io.ReadAll":       "1.16",
+	"io.Discard":       "1.16",
+	"any":              "1.18",
+	"comparable":       "1.18",
+	"min":              "1.21",
+	"max":              "1.21",
+	"clear":            "1.21",
+}
+
+// Classify recognizes common causes of a failed go install, dep ensure or
+// glide install in the tool's output, and returns a hint naming the fix, or
+// "" if the output matches nothing known.
+func Classify(output, goVersion, vendorTool string) string {
+	if m := privateRepo.FindStringSubmatch(output); m != nil {
+		host := m[2] + m[3]
+		if u := repoHostInURL.FindStringSubmatch(host); u != nil {
+			host = u[1]
+		}
+		return warnings.PrivateRepoHint(host, vendorTool)
+	}
+
+	if needed, evidence := requiredGoVersion(output, goVersion); needed != "" {
+		return warnings.GoVersionTooOldHint(goVersion, needed, evidence, vendorTool == "godep")
+	}
+
+	if m := importCycle.FindStringSubmatch(output); m != nil {
+		var cycle []string
+		for _, step := range cycleStep.FindAllStringSubmatch(m[1], -1) {
+			cycle = append(cycle, step[1])
+		}
+		return warnings.ImportCycleHint(cycle)
+	}
+
+	missing := map[string]bool{}
+	for _, re := range missingPackage {
+		for _, m := range re.FindAllStringSubmatch(output, -1) {
+			missing[m[1]] = true
+		}
+	}
+	if len(missing) > 0 {
+		var packages []string
+		for pkg := range missing {
+			packages = append(packages, pkg)
+		}
+		sort.Strings(packages)
+		return warnings.MissingPackageHint(packages, vendorTool)
+	}
+
+	return ""
+}
+
+// requiredGoVersion returns the Go version the output says is needed, and
+// the line of output that says so, when it is newer than goVersion.
+func requiredGoVersion(output, goVersion string) (string, string) {
+	current, err := semver.NewVersion(goVersion)
+	if err != nil {
+		return "", ""
+	}
+	newer := func(version string) bool {
+		v, err := semver.NewVersion(version)
+		return err == nil && v.GreaterThan(current)
+	}
+
+	for _, re := range requiresGo {
+		if m := re.FindStringSubmatch(output); m != nil && newer(m[1]) {
+			return m[1], m[0]
+		}
+	}
+
+	for _, m := range undefined.FindAllStringSubmatch(output, -1) {
+		if version, ok := sinceVersion[m[1]]; ok && newer(version) {
+			return version, m[0]
+		}
+	}
+
+	if m := typeParams.FindString(output); m != "" && newer("1.18") {
+		return "1.18", m
+	}
+	if m := typeAlias.FindString(output); m != "" && newer("1.9") {
+		return "1.9", m
+	}
+
+	return "", ""
+}
@@ -0,0 +1,216 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Enabled reports whether staging output should be JSON lines rather than
+// the usual human readable log.
+func Enabled() bool {
+	return os.Getenv("BP_LOG_FORMAT") == "json"
+}
+
+// Event is one line of JSON output.
+type Event struct {
+	Time       string                 `json:"time"`
+	Type       string                 `json:"type"`
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// FromStep, as the reason for an exit code, reports the code of the step
+// that was running when the phase failed, such as "dep_ensure" or "compile",
+// so failures of a whole phase's Run are told apart.
+const FromStep = "from_step"
+
+// stepCodes gives each step the buildpack announces a stable code, keyed by
+// the start of its message. The scripts that build the buildpack before it
+// runs log the download_go, build_supply and build_finalize steps through
+// scripts/log.sh.
+var stepCodes = []struct {
+	prefix string
+	code   string
+}{
+	{"Checking Godeps/Godeps.json file", "godeps_json"},
+	{"Installing Go tools", "install_tools"},
+	{"Installing ", "install_dependency"},
+	{"Fetching any unsaved dependencies (dep ensure)", "dep_ensure"},
+	{"Fetching any unsaved dependencies (glide install)", "glide_install"},
+	{"Writing license report", "license_report"},
+	{"Checking dependencies against advisory database", "advisory_check"},
+	{"Migrating Godeps/_workspace", "migrate_godeps_workspace"},
+	{"Checking imports", "import_check"},
+	{"Running: ", "compile"},
+	{"Splitting debug symbols", "split_debug_symbols"},
+	{"Pruning droplet", "prune_droplet"},
+	{"Writing software bill of materials", "sbom"},
+	{"Leaving go tool chain", "keep_toolchain"},
+	{"Cleaning up $GOPATH/pkg", "clean_gopath_pkg"},
+	{"Writing build report", "build_report"},
+	{"Writing staging plan", "staging_plan"},
+}
+
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
+const (
+	stepHeader    = "-----> "
+	errorHeader   = "       **ERROR** "
+	warningHeader = "       **WARNING** "
+	protipHeader  = "       PRO TIP: "
+	debugHeader   = "       DEBUG: "
+	infoHeader    = "       "
+)
+
+// Writer is an io.Writer for libbuildpack.Logger that emits one JSON event
+// per logged message. Anything else written to it, such as the output of
+// 'go install', becomes "output" events, one per line.
+type Writer struct {
+	out       io.Writer
+	phase     string
+	reasons   map[int]string
+	start     time.Time
+	step      string
+	stepStart time.Time
+	partial   bytes.Buffer
+}
+
+// NewWriter writes events for phase ("supply" or "finalize") to out.
+// reasons categorizes the phase's exit codes for the final outcome event.
+func NewWriter(out io.Writer, phase string, reasons map[int]string) *Writer {
+	now := time.Now()
+	return &Writer{out: out, phase: phase, reasons: reasons, start: now, step: phase, stepStart: now}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	text := ansiEscape.ReplaceAllString(string(p), "")
+
+	isLogged := strings.HasPrefix(text, stepHeader) || strings.HasPrefix(text, infoHeader)
+	if w.partial.Len() == 0 && isLogged && strings.HasSuffix(text, "\n") {
+		w.logged(strings.TrimSuffix(text, "\n"))
+		return len(p), nil
+	}
+
+	w.partial.WriteString(text)
+	for {
+		line, err := w.partial.ReadString('\n')
+		if err != nil {
+			w.partial.Reset()
+			w.partial.WriteString(line)
+			break
+		}
+		w.emit("output", w.step+".output", strings.TrimSuffix(line, "\n"), w.sinceStep(), nil)
+	}
+
+	return len(p), nil
+}
+
+// Finish flushes buffered output and emits the closing step and outcome
+// events. It does nothing on a nil Writer, so callers can use it whether or
+// not JSON output is enabled.
+func (w *Writer) Finish(exitCode int) {
+	if w == nil {
+		return
+	}
+
+	if w.partial.Len() != 0 {
+		w.emit("output", w.step+".output", w.partial.String(), w.sinceStep(), nil)
+		w.partial.Reset()
+	}
+	w.endStep()
+
+	fields := map[string]interface{}{"exit_code": exitCode}
+	outcome := "success"
+	if exitCode != 0 {
+		outcome = "failure"
+		fields["step"] = w.step
+		reason, ok := w.reasons[exitCode]
+		switch {
+		case !ok:
+			reason = "unknown"
+		case reason == FromStep:
+			reason = w.step
+		}
+		fields["reason"] = reason
+	}
+
+	w.emit("outcome", w.phase+"."+outcome, outcome, int64(time.Since(w.start)/time.Millisecond), fields)
+}
+
+func (w *Writer) logged(text string) {
+	message := func(header string) string {
+		return strings.Replace(strings.TrimPrefix(text, header), "\n"+infoHeader, "\n", -1)
+	}
+
+	switch {
+	case strings.HasPrefix(text, stepHeader):
+		w.endStep()
+		msg := message(stepHeader)
+		w.step, w.stepStart = stepCode(msg), time.Now()
+		w.emit("step", w.step, msg, 0, stepFields(w.step, msg))
+	case strings.HasPrefix(text, errorHeader):
+		w.emit("error", w.step+".error", message(errorHeader), w.sinceStep(), nil)
+	case strings.HasPrefix(text, warningHeader):
+		w.emit("warning", w.step+".warning", message(warningHeader), w.sinceStep(), nil)
+	case strings.HasPrefix(text, protipHeader):
+		w.emit("info", w.step+".protip", message(protipHeader), w.sinceStep(), nil)
+	case strings.HasPrefix(text, debugHeader):
+		w.emit("debug", w.step+".debug", message(debugHeader), w.sinceStep(), nil)
+	default:
+		w.emit("info", w.step+".info", message(infoHeader), w.sinceStep(), nil)
+	}
+}
+
+func (w *Writer) endStep() {
+	if w.step != w.phase {
+		w.emit("step_end", w.step, "", w.sinceStep(), nil)
+	}
+}
+
+func (w *Writer) sinceStep() int64 {
+	return int64(time.Since(w.stepStart) / time.Millisecond)
+}
+
+func (w *Writer) emit(eventType, code, message string, durationMS int64, fields map[string]interface{}) {
+	data, err := json.Marshal(Event{
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		Type:       eventType,
+		Code:       code,
+		Message:    message,
+		DurationMS: durationMS,
+		Fields:     fields,
+	})
+	if err != nil {
+		return
+	}
+
+	w.out.Write(append(data, '\n'))
+}
+
+func stepCode(message string) string {
+	for _, step := range stepCodes {
+		if strings.HasPrefix(message, step.prefix) {
+			return step.code
+		}
+	}
+	return "step"
+}
+
+func stepFields(code, message string) map[string]interface{} {
+	switch code {
+	case "install_dependency":
+		if parts := strings.Fields(message); len(parts) == 3 {
+			return map[string]interface{}{"name": parts[1], "version": parts[2]}
+		}
+	case "compile":
+		return map[string]interface{}{"command": strings.TrimPrefix(message, "Running: ")}
+	}
+	return nil
+}
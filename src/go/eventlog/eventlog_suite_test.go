@@ -0,0 +1,13 @@
+package eventlog_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEventlog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Eventlog Suite")
+}
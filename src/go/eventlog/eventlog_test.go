@@ -0,0 +1,160 @@
+package eventlog_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"go/eventlog"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Eventlog", func() {
+	var (
+		buffer *bytes.Buffer
+		writer *eventlog.Writer
+		logger *libbuildpack.Logger
+	)
+
+	events := func() []eventlog.Event {
+		var events []eventlog.Event
+		scanner := bufio.NewScanner(bytes.NewReader(buffer.Bytes()))
+		for scanner.Scan() {
+			var event eventlog.Event
+			Expect(json.Unmarshal(scanner.Bytes(), &event)).To(Succeed())
+			events = append(events, event)
+		}
+		return events
+	}
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		writer = eventlog.NewWriter(buffer, "finalize", map[int]string{12: eventlog.FromStep, 15: "plan_only"})
+		logger = libbuildpack.NewLogger(writer)
+	})
+
+	Describe("Enabled", func() {
+		var oldFormat string
+
+		BeforeEach(func() {
+			oldFormat = os.Getenv("BP_LOG_FORMAT")
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("BP_LOG_FORMAT", oldFormat)).To(Succeed())
+		})
+
+		It("is true only when $BP_LOG_FORMAT is json", func() {
+			Expect(os.Setenv("BP_LOG_FORMAT", "json")).To(Succeed())
+			Expect(eventlog.Enabled()).To(BeTrue())
+
+			Expect(os.Setenv("BP_LOG_FORMAT", "text")).To(Succeed())
+			Expect(eventlog.Enabled()).To(BeFalse())
+		})
+	})
+
+	It("gives steps their stable codes and fields", func() {
+		logger.BeginStep("Installing go 1.9.2")
+		logger.BeginStep("Fetching any unsaved dependencies (dep ensure)")
+		logger.BeginStep("Running: go install -tags cloudfoundry .")
+		logger.BeginStep("Doing something new")
+
+		var steps []eventlog.Event
+		for _, event := range events() {
+			if event.Type == "step" {
+				steps = append(steps, event)
+			}
+		}
+		Expect(steps).To(HaveLen(4))
+		Expect(steps[0].Code).To(Equal("install_dependency"))
+		Expect(steps[0].Fields).To(Equal(map[string]interface{}{"name": "go", "version": "1.9.2"}))
+		Expect(steps[1].Code).To(Equal("dep_ensure"))
+		Expect(steps[2].Code).To(Equal("compile"))
+		Expect(steps[2].Fields).To(Equal(map[string]interface{}{"command": "go install -tags cloudfoundry ."}))
+		Expect(steps[3].Code).To(Equal("step"))
+	})
+
+	It("ends each step with a step_end event", func() {
+		logger.BeginStep("Checking imports of ./...")
+		logger.BeginStep("Pruning droplet")
+		writer.Finish(0)
+
+		var types, codes []string
+		for _, event := range events() {
+			types = append(types, event.Type)
+			codes = append(codes, event.Code)
+		}
+		Expect(types).To(Equal([]string{"step", "step_end", "step", "step_end", "outcome"}))
+		Expect(codes).To(Equal([]string{"import_check", "import_check", "prune_droplet", "prune_droplet", "finalize.success"}))
+	})
+
+	It("files messages and tool output under the current step", func() {
+		logger.BeginStep("Fetching any unsaved dependencies (glide install)")
+		logger.Warning("slow mirror")
+		logger.Error("could not fetch")
+		logger.Output().Write([]byte("[INFO] Downloading\n[WARN] partial"))
+		writer.Finish(0)
+
+		all := events()
+		Expect(all[1]).To(matchEvent("warning", "glide_install.warning", "slow mirror"))
+		Expect(all[2]).To(matchEvent("error", "glide_install.error", "could not fetch"))
+		Expect(all[3]).To(matchEvent("output", "glide_install.output", "[INFO] Downloading"))
+		Expect(all[4]).To(matchEvent("output", "glide_install.output", "[WARN] partial"))
+	})
+
+	Describe("the outcome event", func() {
+		outcome := func() eventlog.Event {
+			all := events()
+			Expect(all).NotTo(BeEmpty())
+			return all[len(all)-1]
+		}
+
+		It("reports success with the exit code", func() {
+			writer.Finish(0)
+
+			Expect(outcome().Code).To(Equal("finalize.success"))
+			Expect(outcome().Fields).To(Equal(map[string]interface{}{"exit_code": float64(0)}))
+		})
+
+		It("reports the reason for the exit code", func() {
+			logger.BeginStep("Writing staging plan to .cloudfoundry/go-staging-plan.json")
+			writer.Finish(15)
+
+			Expect(outcome().Code).To(Equal("finalize.failure"))
+			Expect(outcome().Fields).To(Equal(map[string]interface{}{"exit_code": float64(15), "step": "staging_plan", "reason": "plan_only"}))
+		})
+
+		It("uses the failing step as the reason when the exit code says so", func() {
+			logger.BeginStep("Fetching any unsaved dependencies (dep ensure)")
+			writer.Finish(12)
+
+			Expect(outcome().Fields["reason"]).To(Equal("dep_ensure"))
+		})
+
+		It("uses the phase as the reason when no step had started", func() {
+			writer.Finish(12)
+
+			Expect(outcome().Fields["reason"]).To(Equal("finalize"))
+		})
+
+		It("reports unknown exit codes as such", func() {
+			writer.Finish(99)
+
+			Expect(outcome().Fields["reason"]).To(Equal("unknown"))
+		})
+	})
+})
+
+// matchEvent matches an event's type, code and message.
+func matchEvent(eventType, code, message string) OmegaMatcher {
+	return And(
+		WithTransform(func(e eventlog.Event) string { return e.Type }, Equal(eventType)),
+		WithTransform(func(e eventlog.Event) string { return e.Code }, Equal(code)),
+		WithTransform(func(e eventlog.Event) string { return e.Message }, Equal(message)),
+	)
+}
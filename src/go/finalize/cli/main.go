@@ -1,54 +1,119 @@
 package main
 
 import (
+	"go/eventlog"
 	"go/finalize"
 	_ "go/hooks"
+	"go/stepcmd"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/cloudfoundry/libbuildpack"
 )
 
+// Exit codes for finalize, and the reasons JSON output reports for them. The
+// README lists both phases' codes.
+const (
+	exitBuildpackDir = 8
+	exitManifest     = 9
+	exitStagingEnv   = 10
+	exitNewFinalizer = 11
+	exitFinalize     = 12
+	exitAfterCompile = 13
+	exitLaunchEnv    = 14
+	exitPlanOnly     = 15
+	exitStepSettings = 16
+)
+
+var failureReasons = map[int]string{
+	exitBuildpackDir: "buildpack_dir",
+	exitManifest:     "manifest",
+	exitStagingEnv:   "staging_environment",
+	exitNewFinalizer: "finalizer_setup",
+	exitFinalize:     eventlog.FromStep,
+	exitAfterCompile: "after_compile_hook",
+	exitLaunchEnv:    "launch_environment",
+	exitPlanOnly:     "plan_only",
+	exitStepSettings: "step_settings",
+}
+
 func main() {
+	var events *eventlog.Writer
 	logger := libbuildpack.NewLogger(os.Stdout)
+	if eventlog.Enabled() {
+		events = eventlog.NewWriter(os.Stdout, "finalize", failureReasons)
+		logger = libbuildpack.NewLogger(events)
+	}
+
+	exit := func(code int) {
+		events.Finish(code)
+		os.Exit(code)
+	}
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
 		logger.Error("Unable to determine buildpack directory: %s", err.Error())
-		os.Exit(8)
+		exit(exitBuildpackDir)
 	}
 
 	manifest, err := libbuildpack.NewManifest(buildpackDir, logger, time.Now())
 	if err != nil {
 		logger.Error("Unable to load buildpack manifest: %s", err.Error())
-		os.Exit(9)
+		exit(exitManifest)
 	}
 
 	stager := libbuildpack.NewStager(os.Args[1:], logger, manifest)
 
 	if err := stager.SetStagingEnvironment(); err != nil {
 		logger.Error("Unable to setup environment variables: %s", err.Error())
-		os.Exit(10)
+		exit(exitStagingEnv)
 	}
 
-	gf, err := finalize.NewFinalizer(stager, &libbuildpack.Command{}, logger)
+	timeouts, err := stepcmd.ParseTimeouts(os.Getenv("GO_STEP_TIMEOUTS"))
 	if err != nil {
-		os.Exit(11)
+		logger.Error("Unable to parse $GO_STEP_TIMEOUTS: %s", err.Error())
+		exit(exitStepSettings)
+	}
+
+	vendorAttempts := 3
+	if value := os.Getenv("GO_VENDOR_ATTEMPTS"); value != "" {
+		vendorAttempts, err = strconv.Atoi(value)
+		if err != nil || vendorAttempts < 1 {
+			logger.Error("Unable to parse $GO_VENDOR_ATTEMPTS: %q is not a positive number", value)
+			exit(exitStepSettings)
+		}
+	}
+
+	gf, err := finalize.NewFinalizer(stager, &stepcmd.Command{Timeouts: timeouts}, logger)
+	if err != nil {
+		exit(exitNewFinalizer)
+	}
+
+	gf.BuildpackDir = buildpackDir
+	gf.VendorAttempts = vendorAttempts
+	gf.VendorBackoff = 5 * time.Second
+	gf.PlanOnly = os.Getenv("GO_STAGING_PLAN") == "true"
+	if version, err := manifest.Version(); err == nil {
+		gf.BuildpackVersion = version
 	}
 
-	if err := finalize.Run(gf); err != nil {
-		os.Exit(12)
+	if err := finalize.Run(gf); err == finalize.ErrPlanOnly {
+		exit(exitPlanOnly)
+	} else if err != nil {
+		exit(exitFinalize)
 	}
 
 	if err := libbuildpack.RunAfterCompile(stager); err != nil {
 		logger.Error("After Compile: %s", err.Error())
-		os.Exit(13)
+		exit(exitAfterCompile)
 	}
 
 	if err := stager.SetLaunchEnvironment(); err != nil {
 		logger.Error("Unable to setup launch environment: %s", err.Error())
-		os.Exit(14)
+		exit(exitLaunchEnv)
 	}
 
 	stager.StagingComplete()
+	events.Finish(0)
 }
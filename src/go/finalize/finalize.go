@@ -2,16 +2,34 @@ package finalize
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"go/advisory"
+	"go/build"
 	"go/data"
+	"go/diagnose"
 	"go/godep"
+	"go/importpath"
+	"go/imports"
+	"go/licenses"
+	"go/lockfile"
+	"go/parser"
+	"go/platform"
+	"go/sbom"
+	"go/token"
 	"go/warnings"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"encoding/json"
 
@@ -25,6 +43,7 @@ type Command interface {
 
 type Stager interface {
 	BuildDir() string
+	CacheDir() string
 	ClearDepDir() error
 	DepDir() string
 	DepsIdx() string
@@ -36,21 +55,70 @@ type Finalizer struct {
 	Command          Command
 	Log              *libbuildpack.Logger
 	VendorTool       string
+	VendorToolReason string
 	GoVersion        string
+	GoVersionSource  string
 	Godep            godep.Godep
 	MainPackageName  string
+	PackageSubdir    string
 	GoPath           string
 	PackageList      []string
+	WebProcess       string
 	BuildFlags       []string
+	TargetGOOS       string
+	TargetGOARCH     string
+	RuntimeSettings  map[string]map[string]string
 	VendorExperiment bool
+	VendorAttempts   int
+	VendorBackoff    time.Duration
+	BuildpackVersion string
+	BuildpackDir     string
+	StepDurations    []StepDuration
+	DebugSymbols     []DebugSymbols
+	PlanOnly         bool
+	GodepsMigrated   bool
+}
+
+type DebugSymbols struct {
+	Binary  string `json:"binary"`
+	BuildID string `json:"build_id"`
+	File    string `json:"file"`
+}
+
+// ErrPlanOnly is returned by Run once the staging plan has been written, so
+// that staging stops before anything is installed or compiled.
+var ErrPlanOnly = errors.New("staging plan written, app not compiled")
+
+type StagingPlan struct {
+	VendorTool       string   `json:"vendor_tool"`
+	VendorToolReason string   `json:"vendor_tool_reason"`
+	GoVersion        string   `json:"go_version"`
+	GoVersionSource  string   `json:"go_version_source"`
+	MainPackage      string   `json:"main_package"`
+	GoPath           string   `json:"gopath"`
+	GoPathInImage    bool     `json:"gopath_in_image"`
+	MainPackageDir   string   `json:"main_package_dir"`
+	GoBin            string   `json:"gobin"`
+	Target           string   `json:"target"`
+	VendorStep       string   `json:"vendor_step,omitempty"`
+	GodepsWorkspace  string   `json:"godeps_workspace,omitempty"`
+	Packages         []string `json:"packages"`
+	CompileCommand   []string `json:"compile_command"`
+}
+
+type StepDuration struct {
+	Step    string  `json:"step"`
+	Seconds float64 `json:"seconds"`
 }
 
 func NewFinalizer(stager Stager, command Command, logger *libbuildpack.Logger) (*Finalizer, error) {
 	config := struct {
 		Config struct {
-			GoVersion  string `yaml:"GoVersion"`
-			VendorTool string `yaml:"VendorTool"`
-			Godep      string `yaml:"Godep"`
+			GoVersion        string `yaml:"GoVersion"`
+			GoVersionSource  string `yaml:"GoVersionSource"`
+			VendorTool       string `yaml:"VendorTool"`
+			VendorToolReason string `yaml:"VendorToolReason"`
+			Godep            string `yaml:"Godep"`
 		} `yaml:"config"`
 	}{}
 	if err := libbuildpack.NewYAML().Load(filepath.Join(stager.DepDir(), "config.yml"), &config); err != nil {
@@ -67,12 +135,14 @@ func NewFinalizer(stager Stager, command Command, logger *libbuildpack.Logger) (
 	}
 
 	return &Finalizer{
-		Stager:     stager,
-		Command:    command,
-		Log:        logger,
-		Godep:      godep,
-		GoVersion:  config.Config.GoVersion,
-		VendorTool: config.Config.VendorTool,
+		Stager:           stager,
+		Command:          command,
+		Log:              logger,
+		Godep:            godep,
+		GoVersion:        config.Config.GoVersion,
+		GoVersionSource:  config.Config.GoVersionSource,
+		VendorTool:       config.Config.VendorTool,
+		VendorToolReason: config.Config.VendorToolReason,
 	}, nil
 }
 
@@ -84,31 +154,57 @@ func Run(gf *Finalizer) error {
 		return err
 	}
 
+	if err := gf.SetPackageSubdir(); err != nil {
+		gf.Log.Error("Invalid package subdirectory: %s", err.Error())
+		return err
+	}
+
+	if err := gf.SetRuntimeSettings(); err != nil {
+		gf.Log.Error("Invalid runtime settings in buildpack.yml: %s", err.Error())
+		return err
+	}
+
+	if gf.PlanOnly {
+		return RunPlan(gf)
+	}
+
 	if err := os.MkdirAll(filepath.Join(gf.Stager.BuildDir(), "bin"), 0755); err != nil {
 		gf.Log.Error("Unable to create <build-dir>/bin: %s", err.Error())
 		return err
 	}
 
+	start := time.Now()
 	if err := gf.SetupGoPath(); err != nil {
 		gf.Log.Error("Unable to setup Go path: %s", err.Error())
 		return err
 	}
+	gf.recordDuration("setup_gopath", start)
 
 	if err := gf.HandleVendorExperiment(); err != nil {
 		gf.Log.Error("Invalid vendor config: %s", err.Error())
 		return err
 	}
 
+	start = time.Now()
+	if err := gf.MigrateGodepsWorkspace(); err != nil {
+		gf.Log.Error("Unable to migrate Godeps/_workspace to vendor/: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("migrate_godeps_workspace", start)
+
+	start = time.Now()
 	if gf.VendorTool == "glide" {
 		if err := gf.RunGlideInstall(); err != nil {
 			gf.Log.Error("Error running 'glide install': %s", err.Error())
 			return err
 		}
+		gf.recordDuration("glide_install", start)
 	} else if gf.VendorTool == "dep" {
 		if err := gf.RunDepEnsure(); err != nil {
 			gf.Log.Error("Error running 'dep ensure': %s", err.Error())
 			return err
 		}
+		gf.recordDuration("dep_ensure", start)
 	}
 
 	gf.SetBuildFlags()
@@ -117,25 +213,154 @@ func Run(gf *Finalizer) error {
 		return err
 	}
 
+	if err := gf.SetTargetPlatform(); err != nil {
+		gf.Log.Error("Unable to set target platform: %s", err.Error())
+		return err
+	}
+
+	start = time.Now()
+	if err := gf.CheckLicenses(); err != nil {
+		gf.Log.Error("Unable to check dependency licenses: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("license_check", start)
+
+	start = time.Now()
+	if err := gf.CheckAdvisories(); err != nil {
+		gf.Log.Error("Unable to check dependencies for known vulnerabilities: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("advisory_check", start)
+
+	start = time.Now()
+	if err := gf.CheckImports(); err != nil {
+		gf.Log.Error("Unable to check imports: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("import_check", start)
+
+	start = time.Now()
 	if err := gf.CompileApp(); err != nil {
 		gf.Log.Error("Unable to compile application: %s", err.Error())
 		return err
 	}
+	gf.recordDuration("compile", start)
+
+	if err := gf.CollectCrossCompiledBinaries(); err != nil {
+		gf.Log.Error("Unable to collect cross compiled binaries: %s", err.Error())
+		return err
+	}
 
+	if err := gf.CheckBinaryArchitecture(); err != nil {
+		gf.Log.Error("Binaries do not match the target platform: %s", err.Error())
+		return err
+	}
+
+	start = time.Now()
+	if err := gf.SplitDebugSymbols(); err != nil {
+		gf.Log.Error("Unable to split debug symbols from binaries: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("split_debug_symbols", start)
+
+	start = time.Now()
+	if err := gf.PruneDroplet(); err != nil {
+		gf.Log.Error("Unable to prune droplet: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("prune_droplet", start)
+
+	start = time.Now()
+	if err := gf.WriteSBOM(); err != nil {
+		gf.Log.Error("Unable to write software bill of materials: %s", err.Error())
+		return err
+	}
+	gf.recordDuration("sbom", start)
+
+	start = time.Now()
 	if err := gf.CreateStartupEnvironment("/tmp"); err != nil {
 		gf.Log.Error("Unable to create startup scripts: %s", err.Error())
 		return err
 	}
+	gf.recordDuration("startup_environment", start)
+
+	if err := gf.WriteBuildReport(); err != nil {
+		gf.Log.Error("Unable to write build report: %s", err.Error())
+		return err
+	}
 
 	return nil
 }
 
+// RunPlan makes the same decisions as Run, against a read-only view of the
+// app, and writes them out instead of acting on them.
+func RunPlan(gf *Finalizer) error {
+	tmpDir, err := ioutil.TempDir("", "gobuildpack.plan")
+	if err != nil {
+		gf.Log.Error("Unable to create plan directory: %s", err.Error())
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gf.GoPath = tmpDir
+	if err := os.MkdirAll(filepath.Dir(gf.mainPackagePath()), 0755); err != nil {
+		gf.Log.Error("Unable to setup Go path: %s", err.Error())
+		return err
+	}
+	if err := os.Symlink(gf.Stager.BuildDir(), gf.mainPackagePath()); err != nil {
+		gf.Log.Error("Unable to setup Go path: %s", err.Error())
+		return err
+	}
+
+	if err := gf.HandleVendorExperiment(); err != nil {
+		gf.Log.Error("Invalid vendor config: %s", err.Error())
+		return err
+	}
+
+	// The app dir is symlinked in, so plan the migration without moving
+	// anything.
+	if gf.migratesGodepsWorkspace() {
+		gf.Godep.WorkspaceExists = false
+		gf.GodepsMigrated = true
+	}
+
+	gf.SetBuildFlags()
+	if err := gf.SetInstallPackages(); err != nil {
+		gf.Log.Error("Unable to determine packages to install: %s", err.Error())
+		return err
+	}
+
+	if err := gf.SetTargetPlatform(); err != nil {
+		gf.Log.Error("Unable to set target platform: %s", err.Error())
+		return err
+	}
+
+	if err := gf.WritePlan(); err != nil {
+		gf.Log.Error("Unable to write staging plan: %s", err.Error())
+		return err
+	}
+
+	gf.Log.Warning("Staging plan written, stopping before install and compile.\nUnset $GO_STAGING_PLAN to stage the app.")
+	return ErrPlanOnly
+}
+
 func (gf *Finalizer) SetMainPackageName() error {
 	switch gf.VendorTool {
 	case "godep":
 		gf.MainPackageName = gf.Godep.ImportPath
 
 	case "glide":
+		if gf.PlanOnly {
+			glide := struct {
+				Package string `yaml:"package"`
+			}{}
+			if err := libbuildpack.NewYAML().Load(filepath.Join(gf.Stager.BuildDir(), "glide.yaml"), &glide); err != nil {
+				return err
+			}
+			gf.MainPackageName = glide.Package
+			return nil
+		}
+
 		buffer := new(bytes.Buffer)
 
 		if err := gf.Command.Execute(gf.Stager.BuildDir(), buffer, ioutil.Discard, "glide", "name"); err != nil {
@@ -147,17 +372,113 @@ func (gf *Finalizer) SetMainPackageName() error {
 		fallthrough
 	case "go_nativevendoring":
 		gf.MainPackageName = os.Getenv("GOPACKAGENAME")
-		if gf.MainPackageName == "" {
+		if gf.MainPackageName != "" {
+			return nil
+		}
+
+		importPath, source, err := importpath.Infer(gf.Stager.BuildDir())
+		if ambiguous, ok := err.(*importpath.AmbiguousError); ok {
+			gf.Log.Error("%s", warnings.AmbiguousImportPathError(ambiguous.Source, ambiguous.Candidates))
+			return err
+		} else if err != nil {
+			return err
+		}
+		if importPath == "" {
 			gf.Log.Error(warnings.NoGOPACKAGENAMEerror())
 			return errors.New("GOPACKAGENAME unset")
 		}
 
+		gf.MainPackageName = importPath
+		gf.Log.Info("Using import path %s from the %s, set $GOPACKAGENAME to override it", importPath, source)
+
 	default:
 		return errors.New("invalid vendor tool")
 	}
 	return nil
 }
 
+// SetPackageSubdir reads $GO_PACKAGE_SUBDIR, for apps pushed from the root
+// of a larger repository. The whole pushed directory is staged as the main
+// package, so sibling packages and relative replace targets resolve, and the
+// package in the subdirectory is the one built and started.
+func (gf *Finalizer) SetPackageSubdir() error {
+	subdir := os.Getenv("GO_PACKAGE_SUBDIR")
+	if subdir == "" {
+		return nil
+	}
+
+	subdir = filepath.Clean(subdir)
+	if subdir == "." {
+		return nil
+	}
+	if filepath.IsAbs(subdir) || subdir == ".." || strings.HasPrefix(subdir, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("$GO_PACKAGE_SUBDIR %s must be a path inside the app", subdir)
+	}
+
+	exists, err := libbuildpack.FileExists(filepath.Join(gf.Stager.BuildDir(), subdir))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("$GO_PACKAGE_SUBDIR %s does not exist in the app", subdir)
+	}
+
+	gf.PackageSubdir = filepath.ToSlash(subdir)
+	gf.Log.Info("Building %s from %s", gf.appImportPath(), gf.MainPackageName)
+	return nil
+}
+
+// runtimeSettings are the environment variables buildpack.yml may set per
+// process type.
+var runtimeSettings = map[string]bool{
+	"GODEBUG":     true,
+	"GOGC":        true,
+	"GOMAXPROCS":  true,
+	"GOMEMLIMIT":  true,
+	"GOTRACEBACK": true,
+}
+
+var processTypePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SetRuntimeSettings reads the go.runtime section of buildpack.yml, which
+// maps process types to Go runtime settings:
+//
+//	go:
+//	  runtime:
+//	    web:
+//	      GOGC: 200
+//	      GOTRACEBACK: crash
+func (gf *Finalizer) SetRuntimeSettings() error {
+	configFile := filepath.Join(gf.Stager.BuildDir(), "buildpack.yml")
+	exists, err := libbuildpack.FileExists(configFile)
+	if err != nil || !exists {
+		return err
+	}
+
+	config := struct {
+		Go struct {
+			Runtime map[string]map[string]string `yaml:"runtime"`
+		} `yaml:"go"`
+	}{}
+	if err := libbuildpack.NewYAML().Load(configFile, &config); err != nil {
+		return err
+	}
+
+	for process, settings := range config.Go.Runtime {
+		if !processTypePattern.MatchString(process) {
+			return fmt.Errorf("%q is not a valid process type", process)
+		}
+		for name := range settings {
+			if !runtimeSettings[name] {
+				return fmt.Errorf("%s is not a supported runtime setting for process type %s", name, process)
+			}
+		}
+	}
+
+	gf.RuntimeSettings = config.Go.Runtime
+	return nil
+}
+
 func (gf *Finalizer) SetupGoPath() error {
 	var skipMoveFile = map[string]bool{
 		".cloudfoundry": true,
@@ -244,33 +565,15 @@ func (gf *Finalizer) SetBuildFlags() {
 }
 
 func (gf *Finalizer) RunDepEnsure() error {
-	vendorDirExists, err := libbuildpack.FileExists(filepath.Join(gf.mainPackagePath(), "vendor"))
+	vendored, err := gf.hasVendoredPackages()
 	if err != nil {
 		return err
 	}
-	runEnsure := true
-
-	if vendorDirExists {
-		numSubDirs := 0
-		files, err := ioutil.ReadDir(filepath.Join(gf.mainPackagePath(), "vendor"))
-		if err != nil {
-			return err
-		}
-		for _, file := range files {
-			if file.IsDir() {
-				numSubDirs++
-			}
-		}
-
-		if numSubDirs > 0 {
-			runEnsure = false
-		}
-	}
 
-	if runEnsure {
+	if !vendored {
 		gf.Log.BeginStep("Fetching any unsaved dependencies (dep ensure)")
 
-		if err := gf.Command.Execute(gf.mainPackagePath(), os.Stdout, os.Stderr, "dep", "ensure"); err != nil {
+		if err := gf.retryTool("dep", "ensure"); err != nil {
 			return err
 		}
 	} else {
@@ -285,33 +588,15 @@ func (gf *Finalizer) RunGlideInstall() error {
 		return nil
 	}
 
-	vendorDirExists, err := libbuildpack.FileExists(filepath.Join(gf.mainPackagePath(), "vendor"))
+	vendored, err := gf.hasVendoredPackages()
 	if err != nil {
 		return err
 	}
-	runGlideInstall := true
-
-	if vendorDirExists {
-		numSubDirs := 0
-		files, err := ioutil.ReadDir(filepath.Join(gf.mainPackagePath(), "vendor"))
-		if err != nil {
-			return err
-		}
-		for _, file := range files {
-			if file.IsDir() {
-				numSubDirs++
-			}
-		}
-
-		if numSubDirs > 0 {
-			runGlideInstall = false
-		}
-	}
 
-	if runGlideInstall {
+	if !vendored {
 		gf.Log.BeginStep("Fetching any unsaved dependencies (glide install)")
 
-		if err := gf.Command.Execute(gf.mainPackagePath(), os.Stdout, os.Stderr, "glide", "install"); err != nil {
+		if err := gf.retryTool("glide", "install"); err != nil {
 			return err
 		}
 	} else {
@@ -346,6 +631,129 @@ func (gf *Finalizer) HandleVendorExperiment() error {
 	return nil
 }
 
+// MigrateGodepsWorkspace moves Godeps/_workspace/src into vendor/ in the
+// GOPATH copy of the app and drops the workspace prefix from imports, so the
+// app builds with 'go install' instead of 'godep go install'. Packages
+// already in vendor/ win. The app dir itself is left alone; the summary
+// explains how to make the migration permanent.
+func (gf *Finalizer) MigrateGodepsWorkspace() error {
+	if !gf.migratesGodepsWorkspace() {
+		if os.Getenv("GO_MIGRATE_GODEPS_WORKSPACE") == "true" && gf.VendorTool == "godep" && gf.Godep.WorkspaceExists {
+			gf.Log.Warning("Not migrating Godeps/_workspace, vendor/ is disabled by $GO15VENDOREXPERIMENT")
+		}
+		return nil
+	}
+
+	gf.Log.BeginStep("Migrating Godeps/_workspace to vendor/")
+
+	workspace := filepath.Join(gf.mainPackagePath(), "Godeps", "_workspace")
+	workspaceSrc := filepath.Join(workspace, "src")
+	vendorDir := filepath.Join(gf.mainPackagePath(), "vendor")
+
+	var packages []string
+	err := filepath.Walk(workspaceSrc, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(workspaceSrc, path)
+		if err == nil {
+			packages = append(packages, rel)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// A package in both places keeps its vendor/ copy as a whole: moving
+	// files one by one would mix two versions of it in one directory.
+	var moved, kept []string
+	for _, rel := range packages {
+		files, err := packageFiles(filepath.Join(workspaceSrc, rel))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		dest := filepath.Join(vendorDir, rel)
+		existing, err := packageFiles(dest)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(existing) > 0 {
+			kept = append(kept, filepath.ToSlash(rel))
+			continue
+		}
+
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Rename(filepath.Join(workspaceSrc, rel, file), filepath.Join(dest, file)); err != nil {
+				return err
+			}
+		}
+		moved = append(moved, filepath.ToSlash(rel))
+	}
+
+	if err := os.RemoveAll(workspace); err != nil {
+		return err
+	}
+
+	oldPrefix := []byte(gf.MainPackageName + "/Godeps/_workspace/src/")
+	var rewritten []string
+	err = filepath.Walk(gf.mainPackagePath(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".go" {
+			return err
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Contains(contents, oldPrefix) {
+			return err
+		}
+
+		rel, err := filepath.Rel(gf.mainPackagePath(), path)
+		if err != nil {
+			return err
+		}
+		rewritten = append(rewritten, filepath.ToSlash(rel))
+		return ioutil.WriteFile(path, bytes.Replace(contents, oldPrefix, nil, -1), info.Mode())
+	})
+	if err != nil {
+		return err
+	}
+
+	gf.Godep.WorkspaceExists = false
+	gf.GodepsMigrated = true
+	gf.Log.Info("%s", warnings.GodepsWorkspaceMigrated(gf.MainPackageName, moved, kept, rewritten))
+	return nil
+}
+
+// migratesGodepsWorkspace reports whether MigrateGodepsWorkspace moves the
+// app's Godeps/_workspace into vendor/.
+func (gf *Finalizer) migratesGodepsWorkspace() bool {
+	return os.Getenv("GO_MIGRATE_GODEPS_WORKSPACE") == "true" &&
+		gf.VendorTool == "godep" && gf.Godep.WorkspaceExists && gf.VendorExperiment
+}
+
+// packageFiles lists the files, but not the subdirectories, of dir.
+func packageFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
 func (gf *Finalizer) SetInstallPackages() error {
 	var packages []string
 	vendorDirExists, err := libbuildpack.FileExists(filepath.Join(gf.mainPackagePath(), "vendor"))
@@ -373,8 +781,9 @@ func (gf *Finalizer) SetInstallPackages() error {
 		} else if len(gf.Godep.Packages) != 0 {
 			packages = gf.Godep.Packages
 		} else {
-			gf.Log.Warning("Installing package '.' (default)")
-			packages = append(packages, ".")
+			if packages, err = gf.defaultPackages(); err != nil {
+				return err
+			}
 		}
 
 		if useVendorDir {
@@ -387,8 +796,9 @@ func (gf *Finalizer) SetInstallPackages() error {
 		}
 
 		if len(packages) == 0 {
-			packages = append(packages, ".")
-			gf.Log.Warning("Installing package '.' (default)")
+			if packages, err = gf.defaultPackages(); err != nil {
+				return err
+			}
 		}
 
 		packages = gf.updatePackagesForVendor(packages)
@@ -398,56 +808,911 @@ func (gf *Finalizer) SetInstallPackages() error {
 	return nil
 }
 
-func (gf *Finalizer) CompileApp() error {
-	cmd := "go"
-	args := []string{"install"}
-	args = append(args, gf.BuildFlags...)
-	args = append(args, gf.PackageList...)
+// SetTargetPlatform reads the platform to build for from $GO_TARGET_GOOS and
+// $GO_TARGET_GOARCH, defaulting to the cell's. Go refuses to install cross
+// compiled binaries to $GOBIN, so when cross compiling they are left in
+// $GOPATH/bin/<goos>_<goarch> for CollectCrossCompiledBinaries. Cross builds
+// also drop -buildmode pie from the build flags: cgo is off when cross
+// compiling, and without it many targets (arm64 before go 1.10, for one)
+// cannot link position independent executables.
+func (gf *Finalizer) SetTargetPlatform() error {
+	gf.TargetGOOS = os.Getenv("GO_TARGET_GOOS")
+	if gf.TargetGOOS == "" {
+		gf.TargetGOOS = "linux"
+	}
+	gf.TargetGOARCH = os.Getenv("GO_TARGET_GOARCH")
+	if gf.TargetGOARCH == "" {
+		gf.TargetGOARCH = platform.HostArch()
+	}
 
-	if gf.VendorTool == "godep" && (gf.Godep.WorkspaceExists || !gf.VendorExperiment) {
-		args = append([]string{"go"}, args...)
-		cmd = "godep"
+	if !gf.crossCompiling() {
+		return nil
 	}
 
-	gf.Log.BeginStep(fmt.Sprintf("Running: %s %s", cmd, strings.Join(args, " ")))
+	gf.Log.Info("Cross compiling for %s/%s", gf.TargetGOOS, gf.TargetGOARCH)
 
-	err := gf.Command.Execute(gf.mainPackagePath(), os.Stdout, os.Stderr, cmd, args...)
-	if err != nil {
+	for i := 0; i+1 < len(gf.BuildFlags); i++ {
+		if gf.BuildFlags[i] == "-buildmode" && gf.BuildFlags[i+1] == "pie" {
+			gf.BuildFlags = append(append([]string{}, gf.BuildFlags[:i]...), gf.BuildFlags[i+2:]...)
+			gf.Log.Info("Building without -buildmode pie: cross compiling disables cgo, which the linker needs for PIE on some targets")
+			break
+		}
+	}
+
+	if err := os.Setenv("GOOS", gf.TargetGOOS); err != nil {
 		return err
 	}
-	return nil
+	if err := os.Setenv("GOARCH", gf.TargetGOARCH); err != nil {
+		return err
+	}
+	return os.Unsetenv("GOBIN")
 }
 
-func (gf *Finalizer) CreateStartupEnvironment(tempDir string) error {
-	err := ioutil.WriteFile(filepath.Join(tempDir, "buildpack-release-step.yml"), []byte(data.ReleaseYAML(gf.MainPackageName)), 0644)
+func (gf *Finalizer) CollectCrossCompiledBinaries() error {
+	if !gf.crossCompiling() {
+		return nil
+	}
+
+	crossBinDir := filepath.Join(gf.GoPath, "bin", gf.TargetGOOS+"_"+gf.TargetGOARCH)
+	files, err := ioutil.ReadDir(crossBinDir)
 	if err != nil {
-		gf.Log.Error("Unable to write relase yml: %s", err.Error())
 		return err
 	}
 
-	if os.Getenv("GO_INSTALL_TOOLS_IN_IMAGE") == "true" {
-		goRuntimeLocation := filepath.Join("$DEPS_DIR", gf.Stager.DepsIdx(), "go"+gf.GoVersion, "go")
-
-		gf.Log.BeginStep("Leaving go tool chain in $GOROOT=%s", goRuntimeLocation)
-
-	} else {
-		if err := gf.Stager.ClearDepDir(); err != nil {
+	for _, file := range files {
+		dest := filepath.Join(gf.Stager.BuildDir(), "bin", file.Name())
+		if err := os.Rename(filepath.Join(crossBinDir, file.Name()), dest); err != nil {
 			return err
 		}
 	}
 
-	if os.Getenv("GO_SETUP_GOPATH_IN_IMAGE") == "true" {
-		gf.Log.BeginStep("Cleaning up $GOPATH/pkg")
-		if err := os.RemoveAll(filepath.Join(gf.GoPath, "pkg")); err != nil {
-			return err
-		}
+	return os.Remove(crossBinDir)
+}
 
-		if err := gf.Stager.WriteProfileD("zzgopath.sh", data.ZZGoPathScript(gf.MainPackageName)); err != nil {
-			return err
+func (gf *Finalizer) CheckBinaryArchitecture() error {
+	if gf.TargetGOOS != "linux" {
+		return nil
+	}
+
+	binDir := filepath.Join(gf.Stager.BuildDir(), "bin")
+	files, err := ioutil.ReadDir(binDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if err := platform.CheckBinary(filepath.Join(binDir, file.Name()), gf.TargetGOARCH); err != nil {
+			return fmt.Errorf("bin/%s: %s", file.Name(), err)
 		}
 	}
 
-	return gf.Stager.WriteProfileD("go.sh", data.GoScript())
+	return nil
+}
+
+// CheckImports fails staging before go install if the packages to be
+// installed import anything that the app, its vendor directories or
+// Godeps/_workspace do not provide, and warns about nested vendor
+// directories that shadow each other. Set $GO_SKIP_IMPORT_CHECK to skip it.
+func (gf *Finalizer) CheckImports() error {
+	if os.Getenv("GO_SKIP_IMPORT_CHECK") == "true" {
+		return nil
+	}
+
+	gf.Log.BeginStep("Checking imports of %s", strings.Join(gf.PackageList, " "))
+
+	shadows, err := imports.Shadowed(gf.mainPackagePath())
+	if err != nil {
+		return err
+	}
+	if len(shadows) > 0 {
+		var shadowed []string
+		for _, s := range shadows {
+			shadowed = append(shadowed, fmt.Sprintf("%s in %s hides %s", s.Path, s.Inner, s.Outer))
+		}
+		gf.Log.Warning("%s", warnings.ShadowedVendorPackages(shadowed))
+	}
+
+	ctx := build.Default
+	ctx.GOOS = gf.TargetGOOS
+	ctx.GOARCH = gf.TargetGOARCH
+	ctx.CgoEnabled = ctx.CgoEnabled && !gf.crossCompiling() && os.Getenv("CGO_ENABLED") != "0"
+	ctx.BuildTags = gf.buildTags()
+
+	checker := imports.Checker{
+		AppDir:     gf.mainPackagePath(),
+		ImportPath: gf.MainPackageName,
+		Workspace:  gf.Godep.WorkspaceExists,
+		Context:    ctx,
+	}
+	repos, err := checker.Check(gf.PackageList)
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, repo := range repos {
+		missing = append(missing, repo.Root)
+		for _, imp := range repo.Imports {
+			missing = append(missing, fmt.Sprintf("    %s (imported by %s)", imp.Path, strings.Join(imp.ImportedBy, ", ")))
+		}
+	}
+	gf.Log.Error("%s", warnings.MissingImportsError(gf.VendorTool, missing))
+	return errors.New("imports missing from vendor/")
+}
+
+// buildTags returns the tags passed to go install in BuildFlags, so that
+// files are selected the way the compiler will select them.
+func (gf *Finalizer) buildTags() []string {
+	var tags []string
+	for i, flag := range gf.BuildFlags {
+		value := ""
+		if (flag == "-tags" || flag == "--tags") && i+1 < len(gf.BuildFlags) {
+			value = gf.BuildFlags[i+1]
+		} else if strings.HasPrefix(flag, "-tags=") || strings.HasPrefix(flag, "--tags=") {
+			value = flag[strings.Index(flag, "=")+1:]
+		}
+		tags = append(tags, strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+	return tags
+}
+
+func (gf *Finalizer) CompileApp() error {
+	cmd, args := gf.compileCommand()
+
+	gf.Log.BeginStep(fmt.Sprintf("Running: %s %s", cmd, strings.Join(args, " ")))
+
+	return gf.runTool(cmd, args...)
+}
+
+// runTool runs a build tool in the main package directory. If it fails, the
+// output it printed is checked for known causes, and a hint logged.
+func (gf *Finalizer) runTool(cmd string, args ...string) error {
+	output, err := gf.execTool(cmd, args...)
+	if err != nil {
+		if hint := diagnose.Classify(output, gf.GoVersion, gf.VendorTool); hint != "" {
+			gf.Log.Error("%s", hint)
+		}
+	}
+	return err
+}
+
+// retryTool runs a vendor tool that fetches dependencies, logging how long
+// each attempt took. Failures are retried up to VendorAttempts times with
+// exponential backoff, unless the output shows a cause that retrying cannot
+// fix.
+func (gf *Finalizer) retryTool(cmd string, args ...string) error {
+	attempts := gf.VendorAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := gf.VendorBackoff
+	name := strings.Join(append([]string{cmd}, args...), " ")
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		output, err := gf.execTool(cmd, args...)
+		elapsed := time.Since(start).Seconds()
+
+		if err == nil {
+			gf.Log.Info("'%s' finished in %.1fs (attempt %d of %d)", name, elapsed, attempt, attempts)
+			return nil
+		}
+		gf.Log.Warning("'%s' failed after %.1fs (attempt %d of %d): %s", name, elapsed, attempt, attempts, err.Error())
+
+		if hint := diagnose.Classify(output, gf.GoVersion, gf.VendorTool); hint != "" {
+			gf.Log.Error("%s", hint)
+			return err
+		}
+		if attempt == attempts {
+			return err
+		}
+
+		gf.Log.Info("Retrying '%s' in %s", name, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// execTool runs a build tool in the main package directory, returning the
+// output it printed as well as logging it.
+func (gf *Finalizer) execTool(cmd string, args ...string) (string, error) {
+	output := new(bytes.Buffer)
+	w := io.MultiWriter(gf.Log.Output(), output)
+
+	err := gf.Command.Execute(gf.mainPackagePath(), w, w, cmd, args...)
+	return output.String(), err
+}
+
+func (gf *Finalizer) WritePlan() error {
+	plan := StagingPlan{
+		VendorTool:       gf.VendorTool,
+		VendorToolReason: gf.VendorToolReason,
+		GoVersion:        gf.GoVersion,
+		GoVersionSource:  gf.GoVersionSource,
+		MainPackage:      gf.MainPackageName,
+		GoPathInImage:    os.Getenv("GO_SETUP_GOPATH_IN_IMAGE") == "true",
+		Packages:         gf.PackageList,
+		Target:           gf.TargetGOOS + "/" + gf.TargetGOARCH,
+	}
+
+	if plan.GoPathInImage {
+		plan.GoPath = gf.Stager.BuildDir()
+		plan.GoBin = filepath.Join(gf.Stager.BuildDir(), "bin")
+	} else {
+		plan.GoPath = filepath.Join(os.TempDir(), "gobuildpack.gopath*", ".go")
+		plan.GoBin = filepath.Join(gf.Stager.BuildDir(), "bin")
+	}
+	plan.MainPackageDir = filepath.Join(plan.GoPath, "src", gf.MainPackageName)
+
+	if gf.VendorTool == "glide" || gf.VendorTool == "dep" {
+		step := map[string]string{"glide": "glide install", "dep": "dep ensure"}[gf.VendorTool]
+		vendored, err := gf.hasVendoredPackages()
+		if err != nil {
+			return err
+		}
+		if vendored {
+			step = "skipped, vendor directory is not empty"
+		}
+		plan.VendorStep = step
+	}
+
+	if gf.GodepsMigrated {
+		plan.GodepsWorkspace = "migrated to vendor/ by $GO_MIGRATE_GODEPS_WORKSPACE"
+	}
+
+	cmd, args := gf.compileCommand()
+	plan.CompileCommand = append([]string{cmd}, args...)
+
+	planFile := filepath.Join(".cloudfoundry", "go-staging-plan.json")
+	gf.Log.BeginStep("Writing staging plan to %s", planFile)
+
+	gf.Log.Info("Vendor tool: %s (%s)", plan.VendorTool, plan.VendorToolReason)
+	gf.Log.Info("Go version: %s (from %s)", plan.GoVersion, plan.GoVersionSource)
+	gf.Log.Info("Main package: %s", plan.MainPackage)
+	gf.Log.Info("GOPATH: %s", plan.GoPath)
+	gf.Log.Info("Main package directory: %s", plan.MainPackageDir)
+	gf.Log.Info("GOBIN: %s", plan.GoBin)
+	gf.Log.Info("Target: %s", plan.Target)
+	if plan.VendorStep != "" {
+		gf.Log.Info("Vendor step: %s", plan.VendorStep)
+	}
+	if plan.GodepsWorkspace != "" {
+		gf.Log.Info("Godeps/_workspace: %s", plan.GodepsWorkspace)
+	}
+	gf.Log.Info("Packages: %s", strings.Join(plan.Packages, " "))
+	gf.Log.Info("Compile command: %s", strings.Join(plan.CompileCommand, " "))
+
+	return libbuildpack.NewJSON().Write(filepath.Join(gf.Stager.BuildDir(), planFile), plan)
+}
+
+// SplitDebugSymbols moves the DWARF out of each compiled binary into
+// <build-id>.debug files, in $GO_DEBUG_SYMBOLS_DIR or the cache dir, and
+// strips the binary as '-ldflags "-s -w"' would. build-ids.json in the same
+// directory maps build IDs to debug files. $GO_DEBUG_SYMBOLS_DIR collects
+// the symbols of every build, while the cache dir only keeps the current
+// build's, as nothing else would ever clean it up.
+func (gf *Finalizer) SplitDebugSymbols() error {
+	if os.Getenv("GO_SPLIT_DEBUG_SYMBOLS") != "true" {
+		return nil
+	}
+
+	symbolsDir := os.Getenv("GO_DEBUG_SYMBOLS_DIR")
+	keepEarlier := symbolsDir != ""
+	if !keepEarlier {
+		symbolsDir = filepath.Join(gf.Stager.CacheDir(), "go-debug-symbols")
+	}
+	if err := os.MkdirAll(symbolsDir, 0755); err != nil {
+		return err
+	}
+
+	gf.Log.BeginStep("Splitting debug symbols into %s", symbolsDir)
+
+	mappingFile := filepath.Join(symbolsDir, "build-ids.json")
+	mapping := map[string]string{}
+	if exists, err := libbuildpack.FileExists(mappingFile); err != nil {
+		return err
+	} else if exists && keepEarlier {
+		if err := libbuildpack.NewJSON().Load(mappingFile, &mapping); err != nil {
+			return err
+		}
+	}
+
+	binDir := filepath.Join(gf.Stager.BuildDir(), "bin")
+	files, err := ioutil.ReadDir(binDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		binary := filepath.Join(binDir, file.Name())
+
+		buildID, err := readBuildID(binary)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file.Name(), err)
+		}
+
+		debugFile := filepath.Join(symbolsDir, strings.Replace(buildID, "/", "-", -1)+".debug")
+		if err := gf.Command.Execute(binDir, gf.Log.Output(), gf.Log.Output(), "objcopy", "--only-keep-debug", binary, debugFile); err != nil {
+			return err
+		}
+		if err := gf.Command.Execute(binDir, gf.Log.Output(), gf.Log.Output(), "objcopy", "--strip-all", "--add-gnu-debuglink="+debugFile, binary); err != nil {
+			return err
+		}
+
+		mapping[buildID] = filepath.Base(debugFile)
+		gf.DebugSymbols = append(gf.DebugSymbols, DebugSymbols{Binary: filepath.Join("bin", file.Name()), BuildID: buildID, File: debugFile})
+		gf.Log.Info("%s: %s", file.Name(), debugFile)
+	}
+
+	if !keepEarlier {
+		if err := removeStaleDebugFiles(symbolsDir, mapping); err != nil {
+			return err
+		}
+	}
+
+	return libbuildpack.NewJSON().Write(mappingFile, mapping)
+}
+
+// removeStaleDebugFiles deletes the debug files in dir that mapping does
+// not name, which were written for earlier builds.
+func removeStaleDebugFiles(dir string, mapping map[string]string) error {
+	current := map[string]bool{}
+	for _, file := range mapping {
+		current[file] = true
+	}
+
+	stale, err := filepath.Glob(filepath.Join(dir, "*.debug"))
+	if err != nil {
+		return err
+	}
+	for _, file := range stale {
+		if current[filepath.Base(file)] {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneDroplet removes everything from the build dir that the compiled app
+// doesn't need at runtime, keeping bin/, the start and environment files and
+// any paths the app lists in $GO_DROPLET_INCLUDE.
+func (gf *Finalizer) PruneDroplet() error {
+	if os.Getenv("GO_PRUNE_DROPLET") != "true" {
+		return nil
+	}
+
+	if os.Getenv("GO_SETUP_GOPATH_IN_IMAGE") == "true" {
+		gf.Log.Warning("Not pruning the droplet, $GO_SETUP_GOPATH_IN_IMAGE keeps the source in the image")
+		return nil
+	}
+
+	keep := []string{"bin", "Procfile", ".profile", ".profile.d", ".cloudfoundry"}
+	include := strings.FieldsFunc(os.Getenv("GO_DROPLET_INCLUDE"), func(r rune) bool { return r == ',' || r == ' ' })
+	for _, path := range include {
+		keep = append(keep, filepath.Clean(path))
+	}
+
+	gf.Log.BeginStep("Pruning droplet, keeping %s", strings.Join(keep, " "))
+
+	removed, err := pruneDir(gf.Stager.BuildDir(), "", keep)
+	if err != nil {
+		return err
+	}
+
+	gf.Log.Info("Removed %d bytes of source and build-only files", removed)
+	return nil
+}
+
+func (gf *Finalizer) CheckLicenses() error {
+	reportFile := filepath.Join(".cloudfoundry", "go-licenses.json")
+	gf.Log.BeginStep("Writing license report to %s", reportFile)
+
+	packages, err := licenses.Scan(
+		filepath.Join(gf.mainPackagePath(), "vendor"),
+		filepath.Join(gf.mainPackagePath(), "Godeps", "_workspace", "src"),
+	)
+	if err != nil {
+		return err
+	}
+
+	report := struct {
+		Packages []licenses.Package `json:"packages"`
+	}{Packages: packages}
+	if err := libbuildpack.NewJSON().Write(filepath.Join(gf.Stager.BuildDir(), reportFile), report); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, pkg := range packages {
+		counts[pkg.License]++
+	}
+	var ids []string
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		gf.Log.Info("%s: %d", id, counts[id])
+	}
+
+	denyList := strings.FieldsFunc(os.Getenv("GO_LICENSE_DENYLIST"), func(r rune) bool { return r == ',' || r == ' ' })
+	denied := licenses.Denied(packages, denyList)
+	if len(denied) != 0 {
+		var offenders []string
+		for _, pkg := range denied {
+			offenders = append(offenders, fmt.Sprintf("%s (%s in %s)", pkg.Path, pkg.License, pkg.File))
+		}
+		gf.Log.Error("%s", warnings.DeniedLicenseError(offenders))
+		return errors.New("denied license found")
+	}
+
+	return nil
+}
+
+func (gf *Finalizer) CheckAdvisories() error {
+	db := os.Getenv("GO_ADVISORY_DB")
+	if db == "" && gf.BuildpackDir != "" {
+		db = filepath.Join(gf.BuildpackDir, "advisories")
+		if exists, err := libbuildpack.FileExists(db); err != nil || !exists {
+			return err
+		}
+	}
+	if db == "" {
+		return nil
+	}
+
+	threshold := advisory.Critical + 1
+	if name := os.Getenv("GO_ADVISORY_FAIL_SEVERITY"); name != "" {
+		var err error
+		if threshold, err = advisory.ParseSeverity(name); err != nil {
+			return err
+		}
+	}
+
+	gf.Log.BeginStep("Checking dependencies against advisory database %s", db)
+
+	entries, err := advisory.Load(db)
+	if err != nil {
+		return err
+	}
+
+	deps, _, err := lockfile.Load(gf.mainPackagePath(), gf.VendorTool, gf.Godep)
+	if err != nil {
+		return err
+	}
+
+	findings := advisory.Check(entries, gf.GoVersion, deps)
+	if len(findings) == 0 {
+		gf.Log.Info("No known vulnerabilities in Go %s or %d dependencies", gf.GoVersion, len(deps))
+		return nil
+	}
+
+	var failures []string
+	for _, f := range findings {
+		message := fmt.Sprintf("%s (%s) %s %s: %s", f.ID, f.SeverityName(), f.Package, f.Version, f.Summary)
+		if f.Fixed != "" {
+			message += fmt.Sprintf("; fixed in %s", f.Fixed)
+		}
+		gf.Log.Warning("%s", message)
+
+		if f.Severity >= threshold {
+			failures = append(failures, fmt.Sprintf("%s in %s %s", f.ID, f.Package, f.Version))
+		} else if f.Severity == advisory.Unknown && threshold <= advisory.Critical {
+			gf.Log.Info("%s has no severity, so $GO_ADVISORY_FAIL_SEVERITY was not checked for it", f.ID)
+		}
+	}
+
+	if len(failures) != 0 {
+		gf.Log.Error("%s", warnings.VulnerableDependenciesError(os.Getenv("GO_ADVISORY_FAIL_SEVERITY"), failures))
+		return errors.New("vulnerable dependencies found")
+	}
+
+	return nil
+}
+
+func (gf *Finalizer) WriteSBOM() error {
+	deps, source, err := lockfile.Load(gf.mainPackagePath(), gf.VendorTool, gf.Godep)
+	if err != nil {
+		return err
+	}
+
+	sbomFile := filepath.Join(".cloudfoundry", "go-sbom.cdx.json")
+	gf.Log.BeginStep("Writing software bill of materials to %s", sbomFile)
+
+	doc := sbom.New(gf.MainPackageName, gf.BuildpackVersion, gf.GoVersion, gf.VendorTool, deps)
+	if err := libbuildpack.NewJSON().Write(filepath.Join(gf.Stager.BuildDir(), sbomFile), doc); err != nil {
+		return err
+	}
+
+	gf.Log.Info("Go toolchain: %s", gf.GoVersion)
+	gf.Log.Info("Vendor tool: %s", gf.VendorTool)
+	if source == "" {
+		gf.Log.Info("Dependencies: none recorded")
+	} else {
+		gf.Log.Info("Dependencies: %d (from %s)", len(deps), source)
+	}
+
+	return nil
+}
+
+func (gf *Finalizer) CreateStartupEnvironment(tempDir string) error {
+	err := ioutil.WriteFile(filepath.Join(tempDir, "buildpack-release-step.yml"), []byte(data.ReleaseYAML(gf.webProcess())), 0644)
+	if err != nil {
+		gf.Log.Error("Unable to write relase yml: %s", err.Error())
+		return err
+	}
+
+	if os.Getenv("GO_INSTALL_TOOLS_IN_IMAGE") == "true" {
+		goRuntimeLocation := filepath.Join("$DEPS_DIR", gf.Stager.DepsIdx(), "go"+gf.GoVersion, "go")
+
+		gf.Log.BeginStep("Leaving go tool chain in $GOROOT=%s", goRuntimeLocation)
+
+	} else {
+		if err := gf.Stager.ClearDepDir(); err != nil {
+			return err
+		}
+	}
+
+	if os.Getenv("GO_SETUP_GOPATH_IN_IMAGE") == "true" {
+		gf.Log.BeginStep("Cleaning up $GOPATH/pkg")
+		if err := os.RemoveAll(filepath.Join(gf.GoPath, "pkg")); err != nil {
+			return err
+		}
+
+		if err := gf.Stager.WriteProfileD("zzgopath.sh", data.ZZGoPathScript(gf.appImportPath())); err != nil {
+			return err
+		}
+	}
+
+	if len(gf.RuntimeSettings) > 0 {
+		if err := gf.Stager.WriteProfileD("go-process-settings.sh", data.ProcessSettingsScript(gf.RuntimeSettings)); err != nil {
+			return err
+		}
+	}
+
+	if err := gf.Stager.WriteProfileD("go-runtime-limits.sh", data.GoRuntimeScript(gf.supportsGOMEMLIMIT())); err != nil {
+		return err
+	}
+
+	return gf.Stager.WriteProfileD("go.sh", data.GoScript())
+}
+
+func (gf *Finalizer) WriteBuildReport() error {
+	reportFile := filepath.Join(".cloudfoundry", "go-build-report.json")
+	gf.Log.BeginStep("Writing build report to %s", reportFile)
+
+	type binary struct {
+		Path   string `json:"path"`
+		SHA256 string `json:"sha256"`
+	}
+	report := struct {
+		BuildpackVersion string         `json:"buildpack_version"`
+		GoVersion        string         `json:"go_version"`
+		VendorTool       string         `json:"vendor_tool"`
+		MainPackage      string         `json:"main_package"`
+		Packages         []string       `json:"packages"`
+		BuildFlags       []string       `json:"build_flags"`
+		LinkerSymbol     string         `json:"linker_symbol,omitempty"`
+		LinkerValue      string         `json:"linker_value,omitempty"`
+		Target           string         `json:"target"`
+		StepDurations    []StepDuration `json:"step_durations"`
+		Binaries         []binary       `json:"binaries"`
+		DebugSymbols     []DebugSymbols `json:"debug_symbols,omitempty"`
+	}{
+		BuildpackVersion: gf.BuildpackVersion,
+		GoVersion:        gf.GoVersion,
+		VendorTool:       gf.VendorTool,
+		MainPackage:      gf.MainPackageName,
+		Packages:         gf.PackageList,
+		BuildFlags:       gf.BuildFlags,
+		LinkerSymbol:     os.Getenv("GO_LINKER_SYMBOL"),
+		LinkerValue:      os.Getenv("GO_LINKER_VALUE"),
+		Target:           gf.TargetGOOS + "/" + gf.TargetGOARCH,
+		StepDurations:    gf.StepDurations,
+		DebugSymbols:     gf.DebugSymbols,
+	}
+
+	binDir := filepath.Join(gf.Stager.BuildDir(), "bin")
+	files, err := ioutil.ReadDir(binDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		sum, err := sha256File(filepath.Join(binDir, file.Name()))
+		if err != nil {
+			return err
+		}
+		report.Binaries = append(report.Binaries, binary{Path: filepath.Join("bin", file.Name()), SHA256: sum})
+	}
+
+	if err := libbuildpack.NewJSON().Write(filepath.Join(gf.Stager.BuildDir(), reportFile), report); err != nil {
+		return err
+	}
+
+	return gf.Stager.WriteProfileD("go-build-report.sh", data.BuildReportScript(reportFile))
+}
+
+func (gf *Finalizer) recordDuration(step string, start time.Time) {
+	gf.StepDurations = append(gf.StepDurations, StepDuration{Step: step, Seconds: time.Since(start).Seconds()})
+}
+
+// pruneDir removes the entries of dir (rel within the build dir) that are
+// neither kept nor lead to a kept path, returning the bytes removed.
+func pruneDir(dir, rel string, keep []string) (int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, file := range files {
+		path := filepath.Join(rel, file.Name())
+
+		kept, parentOfKept := false, false
+		for _, k := range keep {
+			if k == path || strings.HasPrefix(path, k+string(filepath.Separator)) {
+				kept = true
+			} else if strings.HasPrefix(k, path+string(filepath.Separator)) {
+				parentOfKept = true
+			}
+		}
+
+		switch {
+		case kept:
+		case parentOfKept && file.IsDir():
+			size, err := pruneDir(filepath.Join(dir, file.Name()), path, keep)
+			if err != nil {
+				return removed, err
+			}
+			removed += size
+		default:
+			size, err := diskUsage(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return removed, err
+			}
+			if err := os.RemoveAll(filepath.Join(dir, file.Name())); err != nil {
+				return removed, err
+			}
+			removed += size
+		}
+	}
+
+	return removed, nil
+}
+
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// readBuildID returns the GNU build ID of an ELF binary, as debuggers look
+// it up, falling back to the Go build ID that every Go binary carries.
+func readBuildID(file string) (string, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	for _, note := range []struct {
+		section string
+		owner   string
+		hex     bool
+	}{
+		{".note.gnu.build-id", "GNU\x00", true},
+		{".note.go.buildid", "Go\x00\x00", false},
+	} {
+		section := f.Section(note.section)
+		if section == nil {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			return "", err
+		}
+
+		// An ELF note is namesz, descsz and type, followed by the name and
+		// the descriptor, each padded to 4 bytes.
+		if len(data) < 12 {
+			continue
+		}
+		nameSize := f.ByteOrder.Uint32(data[0:4])
+		descSize := f.ByteOrder.Uint32(data[4:8])
+		nameEnd := 12 + int(nameSize)
+		descStart := 12 + int((nameSize+3)&^3)
+		if nameEnd > len(data) || descStart+int(descSize) > len(data) || string(data[12:nameEnd]) != note.owner {
+			continue
+		}
+
+		desc := data[descStart : descStart+int(descSize)]
+		if note.hex {
+			return hex.EncodeToString(desc), nil
+		}
+		return string(desc), nil
+	}
+
+	return "", errors.New("no build ID found")
+}
+
+func sha256File(file string) (string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// appImportPath is the import path of the package that is started, which
+// differs from MainPackageName when building from a subdirectory.
+func (gf *Finalizer) appImportPath() string {
+	return path.Join(gf.MainPackageName, gf.PackageSubdir)
+}
+
+func (gf *Finalizer) webProcess() string {
+	if gf.WebProcess != "" {
+		return gf.WebProcess
+	}
+	return gf.appImportPath()
+}
+
+func (gf *Finalizer) defaultPackage() string {
+	if gf.PackageSubdir == "" {
+		return "."
+	}
+	return "./" + gf.PackageSubdir
+}
+
+// defaultPackages is used when the app doesn't say what to install. A main
+// package at the root is installed on its own; otherwise every main package
+// below it (cmd/<name> layouts) is. The web process is the binary named
+// after the app, or the only one, or else the first.
+func (gf *Finalizer) defaultPackages() ([]string, error) {
+	// In plan mode the main package path is a symlink to the build dir,
+	// which filepath.Walk would not descend into.
+	root, err := filepath.EvalSymlinks(filepath.Join(gf.mainPackagePath(), gf.PackageSubdir))
+	if err != nil {
+		return nil, err
+	}
+
+	if isMainPackage(root) {
+		gf.Log.Warning("Installing package '%s' (default)", gf.defaultPackage())
+		return []string{gf.defaultPackage()}, nil
+	}
+
+	var mainDirs []string
+	err = filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || dir == root {
+			return err
+		}
+		name := info.Name()
+		if name == "vendor" || name == "testdata" || name == "Godeps" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			return filepath.SkipDir
+		}
+
+		if !isMainPackage(dir) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		mainDirs = append(mainDirs, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mainDirs) == 0 {
+		gf.Log.Warning("Installing package '%s' (default)", gf.defaultPackage())
+		return []string{gf.defaultPackage()}, nil
+	}
+
+	var packages []string
+	for _, dir := range mainDirs {
+		packages = append(packages, "./"+path.Join(gf.PackageSubdir, dir))
+	}
+
+	gf.WebProcess = path.Base(mainDirs[0])
+	reason := "the first one found"
+	if len(mainDirs) == 1 {
+		reason = "the only one"
+	}
+	for _, dir := range mainDirs {
+		if path.Base(dir) == path.Base(gf.appImportPath()) {
+			gf.WebProcess, reason = path.Base(dir), "named after the app"
+		}
+	}
+
+	gf.Log.Warning("No main package at the app root, installing the main packages found below it:\n    %s\nThe web process runs '%s', %s.\nSet $GO_INSTALL_PACKAGE_SPEC to choose the packages yourself.", strings.Join(packages, "\n    "), gf.WebProcess, reason)
+	return packages, nil
+}
+
+// isMainPackage reports whether the non-test Go files in dir declare
+// package main. Files that don't parse are left for the compiler to report.
+func isMainPackage(dir string) bool {
+	notTest := func(info os.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }
+
+	pkgs, _ := parser.ParseDir(token.NewFileSet(), dir, notTest, parser.PackageClauseOnly)
+	_, ok := pkgs["main"]
+	return ok
+}
+
+// supportsGOMEMLIMIT reports whether the app was built with go1.19 or
+// later, where the runtime reads GOMEMLIMIT.
+func (gf *Finalizer) supportsGOMEMLIMIT() bool {
+	ver, err := semver.NewVersion(gf.GoVersion)
+	if err != nil {
+		return false
+	}
+	return !ver.LessThan(semver.MustParse("1.19.0"))
+}
+
+func (gf *Finalizer) crossCompiling() bool {
+	return gf.TargetGOOS != "linux" || gf.TargetGOARCH != platform.HostArch()
+}
+
+func (gf *Finalizer) compileCommand() (string, []string) {
+	cmd := "go"
+	args := []string{"install"}
+	args = append(args, gf.BuildFlags...)
+	args = append(args, gf.PackageList...)
+
+	if gf.VendorTool == "godep" && (gf.Godep.WorkspaceExists || !gf.VendorExperiment) {
+		args = append([]string{"go"}, args...)
+		cmd = "godep"
+	}
+
+	return cmd, args
+}
+
+// hasVendoredPackages reports whether vendor/ has any subdirectories, in
+// which case glide and dep are not run.
+func (gf *Finalizer) hasVendoredPackages() (bool, error) {
+	vendorDirExists, err := libbuildpack.FileExists(filepath.Join(gf.mainPackagePath(), "vendor"))
+	if err != nil || !vendorDirExists {
+		return false, err
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(gf.mainPackagePath(), "vendor"))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (gf *Finalizer) mainPackagePath() string {
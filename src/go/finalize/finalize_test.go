@@ -1,13 +1,18 @@
 package finalize_test
 
 import (
+	"errors"
 	"go/finalize"
 	"go/godep"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strings"
+	"time"
 
 	"bytes"
 
@@ -174,6 +179,23 @@ config:
 				Expect(err).To(BeNil())
 				Expect(gf.MainPackageName).To(Equal("go-package-name"))
 			})
+
+			Context("only a staging plan is requested", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(filepath.Join(buildDir, "glide.yaml"), []byte("package: go-package-name\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				JustBeforeEach(func() {
+					gf.PlanOnly = true
+				})
+
+				It("reads the package name from glide.yaml without running glide", func() {
+					err = gf.SetMainPackageName()
+					Expect(err).To(BeNil())
+					Expect(gf.MainPackageName).To(Equal("go-package-name"))
+				})
+			})
 		})
 
 		AssertRequiresAndUsesGOPACKAGENAME := func() {
@@ -186,6 +208,74 @@ config:
 					Expect(buffer.String()).To(ContainSubstring("environment variable to your app's package name"))
 				})
 			})
+			Context("GOPACKAGENAME is not set but the main package has an import comment", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(filepath.Join(buildDir, "main.go"), []byte("package main // import \"github.com/org/app\"\n\nfunc main() {}\n"), 0644)
+					Expect(err).To(BeNil())
+					Expect(os.MkdirAll(filepath.Join(buildDir, "cmd", "worker"), 0755)).To(Succeed())
+					err = ioutil.WriteFile(filepath.Join(buildDir, "cmd", "worker", "main.go"), []byte("package main // import \"github.com/org/app/cmd/worker\"\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				It("uses the import path from the comment", func() {
+					err = gf.SetMainPackageName()
+					Expect(err).To(BeNil())
+
+					Expect(gf.MainPackageName).To(Equal("github.com/org/app"))
+					Expect(buffer.String()).To(ContainSubstring(`Using import path github.com/org/app from the import comment "github.com/org/app"`))
+				})
+			})
+
+			Context("GOPACKAGENAME is not set and import comments disagree", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(filepath.Join(buildDir, "main.go"), []byte("package main // import \"github.com/org/app\"\n"), 0644)
+					Expect(err).To(BeNil())
+					Expect(os.MkdirAll(filepath.Join(buildDir, "cmd", "worker"), 0755)).To(Succeed())
+					err = ioutil.WriteFile(filepath.Join(buildDir, "cmd", "worker", "main.go"), []byte("package main // import \"github.com/fork/app/cmd/worker\"\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				It("logs an error naming both paths", func() {
+					err = gf.SetMainPackageName()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("the import comments name more than one import path:"))
+					Expect(buffer.String()).To(ContainSubstring("github.com/fork/app"))
+					Expect(buffer.String()).To(ContainSubstring("github.com/org/app"))
+				})
+			})
+
+			Context("GOPACKAGENAME is not set but Gopkg.toml names the root package", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(filepath.Join(buildDir, "Gopkg.toml"), []byte("[[constraint]]\n  name = \"github.com/lib/pq\"\n\n[metadata.heroku]\n  root-package = \"github.com/org/app\"\n  go-version = \"go1.9\"\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				It("uses root-package", func() {
+					err = gf.SetMainPackageName()
+					Expect(err).To(BeNil())
+
+					Expect(gf.MainPackageName).To(Equal("github.com/org/app"))
+					Expect(buffer.String()).To(ContainSubstring("Using import path github.com/org/app from the root-package in Gopkg.toml"))
+				})
+			})
+
+			Context("GOPACKAGENAME is not set but the app has a git remote", func() {
+				BeforeEach(func() {
+					Expect(os.MkdirAll(filepath.Join(buildDir, ".git"), 0755)).To(Succeed())
+					err = ioutil.WriteFile(filepath.Join(buildDir, ".git", "config"), []byte("[core]\n\tbare = false\n[remote \"origin\"]\n\turl = git@github.com:org/app.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n[remote \"fork\"]\n\turl = https://user@gitlab.com/fork/app.git\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				It("uses the origin remote", func() {
+					err = gf.SetMainPackageName()
+					Expect(err).To(BeNil())
+
+					Expect(gf.MainPackageName).To(Equal("github.com/org/app"))
+					Expect(buffer.String()).To(ContainSubstring("Using import path github.com/org/app from the origin remote in .git/config"))
+				})
+			})
+
 			Context("GOPACKAGENAME is set", func() {
 				var oldGOPACKAGENAME string
 
@@ -226,6 +316,103 @@ config:
 		})
 	})
 
+	Describe("SetRuntimeSettings", func() {
+		Context("there is no buildpack.yml", func() {
+			It("sets nothing", func() {
+				err = gf.SetRuntimeSettings()
+				Expect(err).To(BeNil())
+
+				Expect(gf.RuntimeSettings).To(BeNil())
+			})
+		})
+
+		Context("buildpack.yml maps process types to runtime settings", func() {
+			BeforeEach(func() {
+				config := "go:\n  runtime:\n    web:\n      GOGC: 200\n      GOTRACEBACK: crash\n    worker:\n      GODEBUG: madvdontneed=1\n"
+				err = ioutil.WriteFile(filepath.Join(buildDir, "buildpack.yml"), []byte(config), 0644)
+				Expect(err).To(BeNil())
+			})
+
+			It("reads the settings", func() {
+				err = gf.SetRuntimeSettings()
+				Expect(err).To(BeNil())
+
+				Expect(gf.RuntimeSettings).To(Equal(map[string]map[string]string{
+					"web":    {"GOGC": "200", "GOTRACEBACK": "crash"},
+					"worker": {"GODEBUG": "madvdontneed=1"},
+				}))
+			})
+		})
+
+		Context("buildpack.yml sets an unsupported variable", func() {
+			BeforeEach(func() {
+				config := "go:\n  runtime:\n    web:\n      LD_PRELOAD: /tmp/x.so\n"
+				err = ioutil.WriteFile(filepath.Join(buildDir, "buildpack.yml"), []byte(config), 0644)
+				Expect(err).To(BeNil())
+			})
+
+			It("returns an error", func() {
+				err = gf.SetRuntimeSettings()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(Equal("LD_PRELOAD is not a supported runtime setting for process type web"))
+			})
+		})
+	})
+
+	Describe("SetPackageSubdir", func() {
+		var oldSubdir string
+
+		BeforeEach(func() {
+			mainPackageName = "github.com/org/monorepo"
+			oldSubdir = os.Getenv("GO_PACKAGE_SUBDIR")
+
+			err = os.MkdirAll(filepath.Join(buildDir, "apps", "api"), 0755)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("GO_PACKAGE_SUBDIR", oldSubdir)).To(Succeed())
+		})
+
+		Context("GO_PACKAGE_SUBDIR names a directory in the app", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_PACKAGE_SUBDIR", "apps/api/")).To(Succeed())
+			})
+
+			It("builds the package in that directory", func() {
+				err = gf.SetPackageSubdir()
+				Expect(err).To(BeNil())
+
+				Expect(gf.PackageSubdir).To(Equal("apps/api"))
+				Expect(buffer.String()).To(ContainSubstring("Building github.com/org/monorepo/apps/api from github.com/org/monorepo"))
+			})
+		})
+
+		Context("GO_PACKAGE_SUBDIR does not exist", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_PACKAGE_SUBDIR", "apps/web")).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				err = gf.SetPackageSubdir()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(Equal("$GO_PACKAGE_SUBDIR apps/web does not exist in the app"))
+			})
+		})
+
+		Context("GO_PACKAGE_SUBDIR points outside the app", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_PACKAGE_SUBDIR", "apps/../../other")).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				err = gf.SetPackageSubdir()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(Equal("$GO_PACKAGE_SUBDIR ../other must be a path inside the app"))
+			})
+		})
+	})
+
 	Describe("SetupGoPath", func() {
 		var (
 			oldGoPath               string
@@ -497,6 +684,56 @@ config:
 			})
 		})
 
+		Context("dep ensure fails for a transient reason", func() {
+			JustBeforeEach(func() {
+				gf.VendorAttempts = 3
+				gf.VendorBackoff = time.Millisecond
+			})
+
+			It("retries with backoff and logs each attempt", func() {
+				gomock.InOrder(
+					mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "dep", "ensure").Return(errors.New("exit status 1")),
+					mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "dep", "ensure").Return(nil),
+				)
+
+				err = gf.RunDepEnsure()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(MatchRegexp(`'dep ensure' failed after \d+\.\ds \(attempt 1 of 3\): exit status 1`))
+				Expect(buffer.String()).To(ContainSubstring("Retrying 'dep ensure' in 1ms"))
+				Expect(buffer.String()).To(MatchRegexp(`'dep ensure' finished in \d+\.\ds \(attempt 2 of 3\)`))
+			})
+
+			It("gives up after the last attempt", func() {
+				mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "dep", "ensure").Return(errors.New("exit status 1")).Times(3)
+
+				err = gf.RunDepEnsure()
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("Retrying 'dep ensure' in 2ms"))
+				Expect(buffer.String()).To(ContainSubstring("(attempt 3 of 3): exit status 1"))
+				Expect(buffer.String()).NotTo(ContainSubstring("Retrying 'dep ensure' in 4ms"))
+			})
+		})
+
+		Context("dep cannot fetch a private repository", func() {
+			It("explains that dependencies must be vendored", func() {
+				mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "dep", "ensure").Do(func(_ string, stdout, _ io.Writer, _ string, _ ...string) {
+					stdout.Write([]byte("fatal: could not read Username for 'https://github.com': terminal prompts disabled\n"))
+				}).Return(errors.New("exit status 1"))
+
+				gf.VendorAttempts = 3
+
+				err = gf.RunDepEnsure()
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("(attempt 1 of 3): exit status 1"))
+				Expect(buffer.String()).NotTo(ContainSubstring("Retrying"))
+				Expect(buffer.String()).To(ContainSubstring("**ERROR** Fetching a dependency from github.com failed because it needs credentials."))
+				Expect(buffer.String()).To(ContainSubstring("Run 'dep ensure' locally, commit vendor/ and push again."))
+			})
+		})
+
 		Context("packages are already vendored", func() {
 			BeforeEach(func() {
 				err = os.MkdirAll(filepath.Join(mainPackagePath, "vendor", "another-package"), 0755)
@@ -510,6 +747,129 @@ config:
 		})
 	})
 
+	Describe("MigrateGodepsWorkspace", func() {
+		var (
+			oldMigrate  string
+			packagePath string
+		)
+
+		BeforeEach(func() {
+			oldMigrate = os.Getenv("GO_MIGRATE_GODEPS_WORKSPACE")
+			Expect(os.Setenv("GO_MIGRATE_GODEPS_WORKSPACE", "true")).To(Succeed())
+
+			vendorTool = "godep"
+			vendorExperiment = true
+			mainPackageName = "github.com/app/name"
+			godepConfig = godep.Godep{ImportPath: mainPackageName, WorkspaceExists: true}
+
+			goPath, err = ioutil.TempDir("", "go-buildpack.gopath.")
+			Expect(err).To(BeNil())
+			packagePath = filepath.Join(goPath, "src", mainPackageName)
+
+			for file, contents := range map[string]string{
+				"main.go": "package main\n\nimport \"github.com/app/name/Godeps/_workspace/src/github.com/dep/a\"\n",
+				"Godeps/_workspace/src/github.com/dep/a/a.go":       "package a\n",
+				"Godeps/_workspace/src/github.com/dep/b/b.go":       "package b // workspace\n",
+				"Godeps/_workspace/src/github.com/dep/b/new.go":     "package b // workspace only\n",
+				"Godeps/_workspace/src/github.com/dep/b/sub/sub.go": "package sub\n",
+				"vendor/github.com/dep/b/b.go":                      "package b // vendor\n",
+			} {
+				err = os.MkdirAll(filepath.Join(packagePath, filepath.Dir(file)), 0755)
+				Expect(err).To(BeNil())
+				err = ioutil.WriteFile(filepath.Join(packagePath, file), []byte(contents), 0644)
+				Expect(err).To(BeNil())
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("GO_MIGRATE_GODEPS_WORKSPACE", oldMigrate)).To(Succeed())
+			Expect(os.RemoveAll(goPath)).To(Succeed())
+			goPath = ""
+			godepConfig = godep.Godep{}
+			vendorExperiment = false
+		})
+
+		It("moves workspace packages into vendor/, keeping existing vendored copies", func() {
+			err = gf.MigrateGodepsWorkspace()
+			Expect(err).To(BeNil())
+
+			Expect(filepath.Join(packagePath, "vendor", "github.com", "dep", "a", "a.go")).To(BeARegularFile())
+			contents, err := ioutil.ReadFile(filepath.Join(packagePath, "vendor", "github.com", "dep", "b", "b.go"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(Equal("package b // vendor\n"))
+			Expect(filepath.Join(packagePath, "Godeps", "_workspace")).NotTo(BeAnExistingFile())
+		})
+
+		It("keeps whole vendored packages rather than mixing in workspace files", func() {
+			err = gf.MigrateGodepsWorkspace()
+			Expect(err).To(BeNil())
+
+			Expect(filepath.Join(packagePath, "vendor", "github.com", "dep", "b", "new.go")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(packagePath, "vendor", "github.com", "dep", "b", "sub", "sub.go")).To(BeARegularFile())
+		})
+
+		It("rewrites workspace import paths", func() {
+			err = gf.MigrateGodepsWorkspace()
+			Expect(err).To(BeNil())
+
+			contents, err := ioutil.ReadFile(filepath.Join(packagePath, "main.go"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(ContainSubstring(`import "github.com/dep/a"`))
+		})
+
+		It("builds with go install from then on", func() {
+			err = gf.MigrateGodepsWorkspace()
+			Expect(err).To(BeNil())
+
+			Expect(gf.Godep.WorkspaceExists).To(BeFalse())
+		})
+
+		It("explains how to commit the migration", func() {
+			err = gf.MigrateGodepsWorkspace()
+			Expect(err).To(BeNil())
+
+			Expect(buffer.String()).To(ContainSubstring("-----> Migrating Godeps/_workspace to vendor/"))
+			Expect(buffer.String()).To(ContainSubstring("Moved into vendor/:\n           github.com/dep/a\n           github.com/dep/b/sub\n"))
+			Expect(buffer.String()).To(ContainSubstring("Kept the existing vendor/ copies of:\n           github.com/dep/b\n"))
+			Expect(buffer.String()).To(ContainSubstring("for pkg in github.com/dep/a github.com/dep/b/sub; do"))
+			Expect(buffer.String()).To(ContainSubstring("rsync -a --ignore-existing --exclude='*/' Godeps/_workspace/src/$pkg/ vendor/$pkg/"))
+			Expect(buffer.String()).To(ContainSubstring("Rewrote Godeps/_workspace imports in:\n           main.go\n"))
+			Expect(buffer.String()).To(ContainSubstring("grep -rl --include=*.go 'github.com/app/name/Godeps/_workspace/src/' . | xargs sed -i 's|github.com/app/name/Godeps/_workspace/src/||g'"))
+			Expect(buffer.String()).To(ContainSubstring("then commit vendor/, Godeps/ and the rewritten files, and run:"))
+			Expect(buffer.String()).To(ContainSubstring("cf unset-env <app> GO_MIGRATE_GODEPS_WORKSPACE"))
+		})
+
+		Context("no imports use the workspace path", func() {
+			BeforeEach(func() {
+				err = ioutil.WriteFile(filepath.Join(packagePath, "main.go"), []byte("package main\n\nimport \"github.com/dep/a\"\n"), 0644)
+				Expect(err).To(BeNil())
+			})
+
+			It("leaves the rewrite out of the commit instructions", func() {
+				err = gf.MigrateGodepsWorkspace()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("Rewrote Godeps/_workspace imports in:\n           (none)\n"))
+				Expect(buffer.String()).NotTo(ContainSubstring("xargs sed"))
+				Expect(buffer.String()).To(ContainSubstring("rm -rf Godeps/_workspace\n       then commit vendor/ and Godeps/, and run:"))
+			})
+		})
+
+		Context("GO_MIGRATE_GODEPS_WORKSPACE is not set", func() {
+			BeforeEach(func() {
+				Expect(os.Unsetenv("GO_MIGRATE_GODEPS_WORKSPACE")).To(Succeed())
+			})
+
+			It("leaves the workspace alone", func() {
+				err = gf.MigrateGodepsWorkspace()
+				Expect(err).To(BeNil())
+
+				Expect(filepath.Join(packagePath, "Godeps", "_workspace", "src", "github.com", "dep", "a", "a.go")).To(BeARegularFile())
+				Expect(gf.Godep.WorkspaceExists).To(BeTrue())
+			})
+		})
+	})
+
 	Describe("HandleVendorExperiment", func() {
 		Context("version is go1.6", func() {
 			var (
@@ -805,6 +1165,78 @@ config:
 				})
 			})
 
+			Context("the app is built from a subdirectory", func() {
+				BeforeEach(func() {
+					err = os.MkdirAll(filepath.Join(mainPackagePath, "apps", "api"), 0755)
+					Expect(err).To(BeNil())
+					err = ioutil.WriteFile(filepath.Join(mainPackagePath, "apps", "api", "main.go"), []byte("package main\n"), 0644)
+					Expect(err).To(BeNil())
+				})
+
+				JustBeforeEach(func() {
+					gf.PackageSubdir = "apps/api"
+				})
+
+				It("installs the package in the subdirectory by default", func() {
+					err = gf.SetInstallPackages()
+					Expect(err).To(BeNil())
+					Expect(gf.PackageList).To(Equal([]string{"./apps/api"}))
+					Expect(buffer.String()).To(ContainSubstring("**WARNING** Installing package './apps/api' (default)"))
+				})
+			})
+
+			Context("there is no main package at the app root", func() {
+				writeGoFile := func(file, contents string) {
+					err = os.MkdirAll(filepath.Join(mainPackagePath, filepath.Dir(file)), 0755)
+					Expect(err).To(BeNil())
+					err = ioutil.WriteFile(filepath.Join(mainPackagePath, file), []byte(contents), 0644)
+					Expect(err).To(BeNil())
+				}
+
+				BeforeEach(func() {
+					writeGoFile("lib.go", "package name\n")
+					writeGoFile("cmd/worker/main.go", "package main\n")
+					writeGoFile("cmd/name/main.go", "package main\n")
+					writeGoFile("cmd/name/main_test.go", "package main_test\n")
+					writeGoFile("internal/util/util.go", "package util\n")
+					writeGoFile("vendor/github.com/dep/tool/main.go", "package main\n")
+					writeGoFile("testdata/example/main.go", "package main\n")
+				})
+
+				It("installs every main package outside vendor/ and testdata", func() {
+					err = gf.SetInstallPackages()
+					Expect(err).To(BeNil())
+					Expect(gf.PackageList).To(Equal([]string{"./cmd/name", "./cmd/worker"}))
+				})
+
+				It("runs the binary named after the app as the web process", func() {
+					err = gf.SetInstallPackages()
+					Expect(err).To(BeNil())
+					Expect(gf.WebProcess).To(Equal("name"))
+				})
+
+				It("explains the decision", func() {
+					err = gf.SetInstallPackages()
+					Expect(err).To(BeNil())
+					Expect(buffer.String()).To(ContainSubstring("**WARNING** No main package at the app root, installing the main packages found below it:"))
+					Expect(buffer.String()).To(ContainSubstring("The web process runs 'name', named after the app."))
+					Expect(buffer.String()).To(ContainSubstring("Set $GO_INSTALL_PACKAGE_SPEC to choose the packages yourself."))
+				})
+
+				Context("the app root is a main package", func() {
+					BeforeEach(func() {
+						writeGoFile("lib.go", "package main\n")
+					})
+
+					It("installs the root package only", func() {
+						err = gf.SetInstallPackages()
+						Expect(err).To(BeNil())
+						Expect(gf.PackageList).To(Equal([]string{"."}))
+						Expect(gf.WebProcess).To(Equal(""))
+					})
+				})
+			})
+
 			Context("VendorExperiment is false", func() {
 				BeforeEach(func() {
 					vendorExperiment = false
@@ -934,114 +1366,1489 @@ config:
 		})
 	})
 
-	Describe("CompileApp", func() {
-		var mainPackagePath string
+	Describe("SetTargetPlatform", func() {
+		var oldEnv map[string]string
 
 		BeforeEach(func() {
-			mainPackageName = "first"
-			packageList = []string{"first", "second"}
-			buildFlags = []string{"-a=1", "-b=2"}
-
-			goPath, err = ioutil.TempDir("", "go-buildpack.gopath")
-			Expect(err).To(BeNil())
-
-			mainPackagePath = filepath.Join(goPath, "src", "first")
-			err = os.MkdirAll(mainPackagePath, 0755)
-			Expect(err).To(BeNil())
+			oldEnv = map[string]string{}
+			for _, name := range []string{"GO_TARGET_GOOS", "GO_TARGET_GOARCH", "GOOS", "GOARCH", "GOBIN"} {
+				oldEnv[name] = os.Getenv(name)
+			}
+			Expect(os.Setenv("GOBIN", filepath.Join(buildDir, "bin"))).To(Succeed())
+			Expect(os.Unsetenv("GOOS")).To(Succeed())
+			Expect(os.Unsetenv("GOARCH")).To(Succeed())
 		})
 
 		AfterEach(func() {
-			err = os.RemoveAll(goPath)
-			Expect(err).To(BeNil())
+			for name, value := range oldEnv {
+				Expect(os.Setenv(name, value)).To(Succeed())
+			}
 		})
 
-		Context("the tool is godep", func() {
+		Context("no target is configured", func() {
 			BeforeEach(func() {
-				vendorTool = "godep"
+				Expect(os.Unsetenv("GO_TARGET_GOOS")).To(Succeed())
+				Expect(os.Unsetenv("GO_TARGET_GOARCH")).To(Succeed())
 			})
-			Context("godeps workspace dir exists", func() {
-				BeforeEach(func() {
-					godepConfig = godep.Godep{WorkspaceExists: true}
-				})
 
-				It("wraps the install command with godep", func() {
-					mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "godep", "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+			It("builds for the cell's platform", func() {
+				gf.SetBuildFlags()
+				err = gf.SetTargetPlatform()
+				Expect(err).To(BeNil())
 
-					err = gf.CompileApp()
-					Expect(err).To(BeNil())
+				Expect(gf.BuildFlags).To(Equal([]string{"-tags", "cloudfoundry", "-buildmode", "pie"}))
 
-					Expect(buffer.String()).To(ContainSubstring("-----> Running: godep go install -a=1 -b=2 first second"))
-				})
+				Expect(gf.TargetGOOS).To(Equal("linux"))
+				Expect(gf.TargetGOARCH).To(Equal(runtime.GOARCH))
+				Expect(os.Getenv("GOARCH")).To(Equal(""))
+				Expect(os.Getenv("GOBIN")).To(Equal(filepath.Join(buildDir, "bin")))
 			})
+		})
 
-			Context("godeps workspace dir does not exist", func() {
-				BeforeEach(func() {
-					godepConfig = godep.Godep{WorkspaceExists: false}
-				})
+		Context("another architecture is configured", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_TARGET_GOOS", "linux")).To(Succeed())
+				Expect(os.Setenv("GO_TARGET_GOARCH", "s390x")).To(Succeed())
+			})
+
+			It("cross compiles without GOBIN", func() {
+				err = gf.SetTargetPlatform()
+				Expect(err).To(BeNil())
+
+				Expect(os.Getenv("GOOS")).To(Equal("linux"))
+				Expect(os.Getenv("GOARCH")).To(Equal("s390x"))
+				Expect(os.Getenv("GOBIN")).To(Equal(""))
+				Expect(buffer.String()).To(ContainSubstring("Cross compiling for linux/s390x"))
+			})
+
+			It("builds without -buildmode pie", func() {
+				gf.SetBuildFlags()
+				err = gf.SetTargetPlatform()
+				Expect(err).To(BeNil())
+
+				Expect(gf.BuildFlags).To(Equal([]string{"-tags", "cloudfoundry"}))
+				Expect(buffer.String()).To(ContainSubstring("Building without -buildmode pie: cross compiling disables cgo"))
+			})
+		})
+	})
+
+	Describe("CollectCrossCompiledBinaries", func() {
+		BeforeEach(func() {
+			goPath, err = ioutil.TempDir("", "go-buildpack.gopath.")
+			Expect(err).To(BeNil())
+
+			err = os.MkdirAll(filepath.Join(goPath, "bin", "linux_s390x"), 0755)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(goPath, "bin", "linux_s390x", "app"), []byte("binary"), 0755)
+			Expect(err).To(BeNil())
+			err = os.MkdirAll(filepath.Join(buildDir, "bin"), 0755)
+			Expect(err).To(BeNil())
+		})
+
+		JustBeforeEach(func() {
+			gf.TargetGOOS = "linux"
+			gf.TargetGOARCH = "s390x"
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(goPath)).To(Succeed())
+			goPath = ""
+		})
+
+		It("moves the binaries into <build-dir>/bin", func() {
+			err = gf.CollectCrossCompiledBinaries()
+			Expect(err).To(BeNil())
+
+			Expect(filepath.Join(buildDir, "bin", "app")).To(BeARegularFile())
+			Expect(filepath.Join(goPath, "bin", "linux_s390x")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("CheckBinaryArchitecture", func() {
+		BeforeEach(func() {
+			err = os.MkdirAll(filepath.Join(buildDir, "bin"), 0755)
+			Expect(err).To(BeNil())
+
+			// the test binary is a real executable for the cell's architecture
+			err = libbuildpack.CopyFile(os.Args[0], filepath.Join(buildDir, "bin", "app"))
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(buildDir, "bin", "script"), []byte("#!/bin/sh\n"), 0755)
+			Expect(err).To(BeNil())
+		})
+
+		JustBeforeEach(func() {
+			gf.TargetGOOS = "linux"
+		})
+
+		Context("the binaries match the target", func() {
+			JustBeforeEach(func() {
+				gf.TargetGOARCH = runtime.GOARCH
+			})
+
+			It("passes", func() {
+				err = gf.CheckBinaryArchitecture()
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("the binaries are for another architecture", func() {
+			JustBeforeEach(func() {
+				gf.TargetGOARCH = "s390x"
+			})
+
+			It("names the binary and its machine type", func() {
+				err = gf.CheckBinaryArchitecture()
+				Expect(err).NotTo(BeNil())
+
+				Expect(err.Error()).To(HavePrefix("bin/app: built for "))
+				Expect(err.Error()).To(ContainSubstring("expected EM_S390 for GOARCH s390x"))
+			})
+		})
+	})
+
+	Describe("CompileApp", func() {
+		var mainPackagePath string
+
+		BeforeEach(func() {
+			mainPackageName = "first"
+			packageList = []string{"first", "second"}
+			buildFlags = []string{"-a=1", "-b=2"}
+
+			goPath, err = ioutil.TempDir("", "go-buildpack.gopath")
+			Expect(err).To(BeNil())
+
+			mainPackagePath = filepath.Join(goPath, "src", "first")
+			err = os.MkdirAll(mainPackagePath, 0755)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			err = os.RemoveAll(goPath)
+			Expect(err).To(BeNil())
+		})
+
+		Context("the tool is godep", func() {
+			BeforeEach(func() {
+				vendorTool = "godep"
+			})
+			Context("godeps workspace dir exists", func() {
+				BeforeEach(func() {
+					godepConfig = godep.Godep{WorkspaceExists: true}
+				})
+
+				It("wraps the install command with godep", func() {
+					mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "godep", "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+
+					err = gf.CompileApp()
+					Expect(err).To(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("-----> Running: godep go install -a=1 -b=2 first second"))
+				})
+			})
+
+			Context("godeps workspace dir does not exist", func() {
+				BeforeEach(func() {
+					godepConfig = godep.Godep{WorkspaceExists: false}
+				})
+
+				Context("vendor experiment is true", func() {
+					BeforeEach(func() {
+						vendorExperiment = true
+					})
+
+					It("does not wrap the install command with godep", func() {
+						mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+
+						err = gf.CompileApp()
+						Expect(err).To(BeNil())
+
+						Expect(buffer.String()).To(ContainSubstring("-----> Running: go install -a=1 -b=2 first second"))
+					})
+
+				})
+
+				Context("vendor experiment is false", func() {
+					BeforeEach(func() {
+						vendorExperiment = false
+					})
+
+					It("logs and runs the install command wrapped with godep", func() {
+						mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "godep", "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+
+						err = gf.CompileApp()
+						Expect(err).To(BeNil())
+
+						Expect(buffer.String()).To(ContainSubstring("-----> Running: godep go install -a=1 -b=2 first second"))
+					})
+				})
+			})
+		})
+
+		AssertLogsAndRunsGenericInstallCommand := func() {
+			It("logs and runs the install command it is going to run", func() {
+				mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+
+				err = gf.CompileApp()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("-----> Running: go install -a=1 -b=2 first second"))
+			})
+		}
+
+		Context("the tool is glide", func() {
+			BeforeEach(func() {
+				vendorTool = "glide"
+			})
+			AssertLogsAndRunsGenericInstallCommand()
+		})
+
+		Context("the tool is dep", func() {
+			BeforeEach(func() {
+				vendorTool = "dep"
+			})
+			AssertLogsAndRunsGenericInstallCommand()
+		})
+
+		Context("the tool is go_nativevendoring", func() {
+			BeforeEach(func() {
+				vendorTool = "go_nativevendoring"
+			})
+			AssertLogsAndRunsGenericInstallCommand()
+		})
+
+		Context("go install fails", func() {
+			var output string
+
+			BeforeEach(func() {
+				vendorTool = "go_nativevendoring"
+				goVersion = "1.9.2"
+			})
+
+			JustBeforeEach(func() {
+				mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "go", "install", "-a=1", "-b=2", "first", "second").Do(func(_ string, stdout, _ io.Writer, _ string, _ ...string) {
+					stdout.Write([]byte(output))
+				}).Return(errors.New("exit status 1"))
+			})
+
+			Context("packages are missing", func() {
+				BeforeEach(func() {
+					output = "main.go:5:2: cannot find package \"github.com/lib/pq\" in any of:\n\t/tmp/go/src/github.com/lib/pq (from $GOROOT)\n"
+				})
+
+				It("shows the output and names the missing packages", func() {
+					err = gf.CompileApp()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("/tmp/go/src/github.com/lib/pq (from $GOROOT)"))
+					Expect(buffer.String()).To(ContainSubstring("**ERROR** The build could not find these packages:"))
+					Expect(buffer.String()).To(ContainSubstring("Copy them into vendor/ and push again."))
+				})
+			})
+
+			Context("packages import each other", func() {
+				BeforeEach(func() {
+					output = "import cycle not allowed\npackage first/a\n\timports first/b\n\timports first/a\n"
+				})
+
+				It("shows the cycle", func() {
+					err = gf.CompileApp()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("**ERROR** Your packages import each other in a cycle"))
+					Expect(buffer.String()).To(MatchRegexp(`first/a\n\s+imports first/b\n\s+imports first/a`))
+				})
+			})
+
+			Context("the code needs a newer Go", func() {
+				BeforeEach(func() {
+					output = "# first\n./main.go:10:7: undefined: strings.Builder\n"
+				})
+
+				It("names the version needed", func() {
+					err = gf.CompileApp()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("**ERROR** Your code needs go 1.10 or later, but go 1.9.2 was used:"))
+					Expect(buffer.String()).To(ContainSubstring("cf set-env <app> GOVERSION go1.10"))
+				})
+			})
+
+			Context("the output matches no known cause", func() {
+				BeforeEach(func() {
+					output = "./main.go:3:1: syntax error: non-declaration statement outside function body\n"
+				})
+
+				It("only shows the output", func() {
+					err = gf.CompileApp()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("non-declaration statement outside function body"))
+					Expect(buffer.String()).NotTo(ContainSubstring("**ERROR**"))
+				})
+			})
+		})
+	})
+
+	Describe("CheckLicenses", func() {
+		var mainPackagePath string
+
+		writeLicense := func(dir, name, text string) {
+			err := os.MkdirAll(filepath.Join(mainPackagePath, dir), 0755)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(mainPackagePath, dir, name), []byte(text), 0644)
+			Expect(err).To(BeNil())
+		}
+
+		BeforeEach(func() {
+			mainPackageName = "a/package/name"
+			goPath, err = ioutil.TempDir("", "go-buildpack.package")
+			Expect(err).To(BeNil())
+
+			mainPackagePath = filepath.Join(goPath, "src", mainPackageName)
+
+			writeLicense("vendor/github.com/a/mit", "LICENSE", `The MIT License (MIT)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software")`)
+			writeLicense("vendor/github.com/b/apache", "LICENSE.txt", `
+                                 Apache License
+                           Version 2.0, January 2004`)
+			writeLicense("Godeps/_workspace/src/github.com/c/affero", "COPYING", `
+                    GNU AFFERO GENERAL PUBLIC LICENSE
+                       Version 3, 19 November 2007`)
+			writeLicense("vendor/github.com/d/custom", "LICENSE", "All rights reserved.")
+		})
+
+		AfterEach(func() {
+			err = os.RemoveAll(goPath)
+			Expect(err).To(BeNil())
+		})
+
+		It("writes a license report into the droplet", func() {
+			err = gf.CheckLicenses()
+			Expect(err).To(BeNil())
+
+			report := struct {
+				Packages []struct {
+					Package string `json:"package"`
+					File    string `json:"file"`
+					License string `json:"license"`
+				} `json:"packages"`
+			}{}
+			err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-licenses.json"), &report)
+			Expect(err).To(BeNil())
+
+			Expect(report.Packages).To(HaveLen(4))
+			Expect(report.Packages[0].Package).To(Equal("github.com/a/mit"))
+			Expect(report.Packages[0].License).To(Equal("MIT"))
+			Expect(report.Packages[1].Package).To(Equal("github.com/b/apache"))
+			Expect(report.Packages[1].File).To(Equal("LICENSE.txt"))
+			Expect(report.Packages[1].License).To(Equal("Apache-2.0"))
+			Expect(report.Packages[2].Package).To(Equal("github.com/c/affero"))
+			Expect(report.Packages[2].License).To(Equal("AGPL-3.0"))
+			Expect(report.Packages[3].License).To(Equal("UNKNOWN"))
+		})
+
+		It("logs a summary of the licenses found", func() {
+			err = gf.CheckLicenses()
+			Expect(err).To(BeNil())
+
+			Expect(buffer.String()).To(ContainSubstring("-----> Writing license report to .cloudfoundry/go-licenses.json"))
+			Expect(buffer.String()).To(ContainSubstring("AGPL-3.0: 1"))
+			Expect(buffer.String()).To(ContainSubstring("MIT: 1"))
+		})
+
+		Context("vendored packages ship full license texts", func() {
+			var oldGoLicenseDenylist string
+
+			BeforeEach(func() {
+				oldGoLicenseDenylist = os.Getenv("GO_LICENSE_DENYLIST")
+				err = os.Setenv("GO_LICENSE_DENYLIST", "AGPL")
+				Expect(err).To(BeNil())
+
+				err = os.RemoveAll(filepath.Join(mainPackagePath, "Godeps"))
+				Expect(err).To(BeNil())
+
+				for _, id := range []string{"GPL-2.0", "GPL-3.0", "MPL-2.0"} {
+					text, err := ioutil.ReadFile(filepath.Join("testdata", "licenses", id))
+					Expect(err).To(BeNil())
+					writeLicense("vendor/github.com/full/"+strings.ToLower(id), "LICENSE", string(text))
+				}
+			})
+
+			AfterEach(func() {
+				err = os.Setenv("GO_LICENSE_DENYLIST", oldGoLicenseDenylist)
+				Expect(err).To(BeNil())
+			})
+
+			It("classifies each text by its own title, not the licenses it quotes", func() {
+				err = gf.CheckLicenses()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("GPL-2.0: 1"))
+				Expect(buffer.String()).To(ContainSubstring("GPL-3.0: 1"))
+				Expect(buffer.String()).To(ContainSubstring("MPL-2.0: 1"))
+				Expect(buffer.String()).NotTo(ContainSubstring("AGPL-3.0"))
+			})
+		})
+
+		Context("vendored license texts name other licenses", func() {
+			BeforeEach(func() {
+				err = os.RemoveAll(filepath.Join(mainPackagePath, "Godeps"))
+				Expect(err).To(BeNil())
+				err = os.RemoveAll(filepath.Join(mainPackagePath, "vendor"))
+				Expect(err).To(BeNil())
+
+				writeLicense("vendor/github.com/mixed/mit", "LICENSE", `The MIT License (MIT)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+The files in contrib/ are derived from another project and are licensed under
+the GNU General Public License version 2, see contrib/COPYING.`)
+				writeLicense("vendor/github.com/mixed/bsd", "LICENSE", `Copyright (c) 2015 The Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+   * Redistributions of source code must retain the above copyright notice.
+   * Neither the name of the copyright holder nor the names of its
+     contributors may be used to endorse or promote products derived from
+     this software without specific prior written permission.
+
+Alternatively, this software may be distributed under the terms of the
+GNU Lesser General Public License version 2.1 as published by the Free
+Software Foundation.`)
+				writeLicense("vendor/github.com/mixed/checker", "license.go", "package checker\n")
+				writeLicense("vendor/github.com/mixed/checker", "licenses_test.go", "package checker\n")
+			})
+
+			It("classifies each text by the license it starts with and skips source files", func() {
+				err = gf.CheckLicenses()
+				Expect(err).To(BeNil())
+
+				report := struct {
+					Packages []struct {
+						Package string `json:"package"`
+						License string `json:"license"`
+					} `json:"packages"`
+				}{}
+				err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-licenses.json"), &report)
+				Expect(err).To(BeNil())
+
+				Expect(report.Packages).To(HaveLen(2))
+				Expect(report.Packages[0].Package).To(Equal("github.com/mixed/bsd"))
+				Expect(report.Packages[0].License).To(Equal("BSD-3-Clause"))
+				Expect(report.Packages[1].Package).To(Equal("github.com/mixed/mit"))
+				Expect(report.Packages[1].License).To(Equal("MIT"))
+			})
+		})
+
+		Context("GO_LICENSE_DENYLIST is set", func() {
+			var oldGoLicenseDenylist string
+
+			BeforeEach(func() {
+				oldGoLicenseDenylist = os.Getenv("GO_LICENSE_DENYLIST")
+			})
+
+			AfterEach(func() {
+				err = os.Setenv("GO_LICENSE_DENYLIST", oldGoLicenseDenylist)
+				Expect(err).To(BeNil())
+			})
+
+			Context("a vendored license is denied", func() {
+				BeforeEach(func() {
+					err = os.Setenv("GO_LICENSE_DENYLIST", "AGPL,GPL-2.0")
+					Expect(err).To(BeNil())
+				})
+
+				It("names the offending package and returns an error", func() {
+					err = gf.CheckLicenses()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("**ERROR** Vendored dependencies use licenses denied by $GO_LICENSE_DENYLIST:"))
+					Expect(buffer.String()).To(ContainSubstring("github.com/c/affero (AGPL-3.0 in COPYING)"))
+					Expect(buffer.String()).NotTo(ContainSubstring("github.com/a/mit ("))
+				})
+			})
+
+			Context("no vendored license is denied", func() {
+				BeforeEach(func() {
+					err = os.Setenv("GO_LICENSE_DENYLIST", "GPL-3.0")
+					Expect(err).To(BeNil())
+				})
+
+				It("does not return an error", func() {
+					err = gf.CheckLicenses()
+					Expect(err).To(BeNil())
+				})
+			})
+		})
+	})
+
+	Describe("CheckImports", func() {
+		var (
+			mainPackagePath string
+			writeGo         func(dir, contents string)
+		)
+
+		BeforeEach(func() {
+			mainPackageName = "github.com/org/app"
+			vendorTool = "dep"
+			packageList = []string{"./cmd/..."}
+
+			goPath, err = ioutil.TempDir("", "go-buildpack.package")
+			Expect(err).To(BeNil())
+			mainPackagePath = filepath.Join(goPath, "src", mainPackageName)
+
+			writeGo = func(dir, contents string) {
+				Expect(os.MkdirAll(filepath.Join(mainPackagePath, dir), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(mainPackagePath, dir, "main.go"), []byte(contents), 0644)).To(Succeed())
+			}
+
+			writeGo("cmd/web", "package main\n\nimport (\n\t\"fmt\"\n\t\"github.com/org/app/store\"\n\t\"github.com/gin-gonic/gin\"\n)\n")
+			writeGo("store", "package store\n\nimport \"github.com/lib/pq\"\n")
+			writeGo("vendor/github.com/gin-gonic/gin", "package gin\n\nimport \"github.com/ugorji/go/codec\"\n")
+			writeGo("vendor/github.com/ugorji/go/codec", "package codec\n")
+		})
+
+		JustBeforeEach(func() {
+			gf.TargetGOOS = "linux"
+			gf.TargetGOARCH = runtime.GOARCH
+		})
+
+		AfterEach(func() {
+			packageList = nil
+			vendorTool = ""
+			Expect(os.RemoveAll(goPath)).To(Succeed())
+		})
+
+		Context("every import is available", func() {
+			BeforeEach(func() {
+				writeGo("vendor/github.com/lib/pq", "package pq\n")
+			})
+
+			It("does not fail", func() {
+				err = gf.CheckImports()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("Checking imports of ./cmd/..."))
+				Expect(buffer.String()).NotTo(ContainSubstring("**ERROR**"))
+			})
+		})
+
+		Context("imports are missing from vendor/", func() {
+			BeforeEach(func() {
+				writeGo("cmd/worker", "package main\n\nimport (\n\t\"github.com/lib/pq/oid\"\n\t\"github.com/lib/pq\"\n\t\"gopkg.in/yaml.v2\"\n)\n")
+				Expect(ioutil.WriteFile(filepath.Join(mainPackagePath, "cmd", "worker", "worker_windows.go"), []byte("package main\n\nimport \"github.com/windows/only\"\n"), 0644)).To(Succeed())
+			})
+
+			It("lists every missing import grouped by repository", func() {
+				err = gf.CheckImports()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(Equal("imports missing from vendor/"))
+
+				Expect(buffer.String()).To(MatchRegexp(`github.com/lib/pq\n\s+github.com/lib/pq \(imported by cmd/worker, store\)\n\s+github.com/lib/pq/oid \(imported by cmd/worker\)\n\s+gopkg.in/yaml.v2\n\s+gopkg.in/yaml.v2 \(imported by cmd/worker\)\n`))
+				Expect(buffer.String()).To(ContainSubstring("Add them to Gopkg.toml and run 'dep ensure', or commit vendor/."))
+				Expect(buffer.String()).NotTo(ContainSubstring("github.com/windows/only"))
+				Expect(buffer.String()).NotTo(ContainSubstring("github.com/gin-gonic/gin ("))
+			})
+
+			Context("files are selected by build tags", func() {
+				BeforeEach(func() {
+					Expect(ioutil.WriteFile(filepath.Join(mainPackagePath, "cmd", "worker", "dotenv.go"), []byte("// +build !cloudfoundry\n\npackage main\n\nimport _ \"github.com/joho/godotenv/autoload\"\n"), 0644)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(mainPackagePath, "cmd", "worker", "cf.go"), []byte("// +build cloudfoundry\n\npackage main\n\nimport _ \"github.com/cloudfoundry-community/go-cfenv\"\n"), 0644)).To(Succeed())
+				})
+
+				JustBeforeEach(func() {
+					gf.SetBuildFlags()
+				})
+
+				It("checks the files go install compiles with -tags cloudfoundry", func() {
+					err = gf.CheckImports()
+					Expect(err).NotTo(BeNil())
+
+					Expect(buffer.String()).To(ContainSubstring("github.com/cloudfoundry-community/go-cfenv (imported by cmd/worker)"))
+					Expect(buffer.String()).NotTo(ContainSubstring("github.com/joho/godotenv"))
+				})
+			})
+
+			Context("GO_SKIP_IMPORT_CHECK is true", func() {
+				var oldSkip string
+
+				BeforeEach(func() {
+					oldSkip = os.Getenv("GO_SKIP_IMPORT_CHECK")
+					Expect(os.Setenv("GO_SKIP_IMPORT_CHECK", "true")).To(Succeed())
+				})
+
+				AfterEach(func() {
+					Expect(os.Setenv("GO_SKIP_IMPORT_CHECK", oldSkip)).To(Succeed())
+				})
+
+				It("does not check", func() {
+					Expect(gf.CheckImports()).To(Succeed())
+					Expect(buffer.String()).To(Equal(""))
+				})
+			})
+		})
+
+		Context("a vendored package has its own vendor directory", func() {
+			BeforeEach(func() {
+				writeGo("vendor/github.com/lib/pq", "package pq\n")
+				writeGo("vendor/github.com/gin-gonic/gin/vendor/github.com/ugorji/go/codec", "package codec\n")
+			})
+
+			It("warns that the nested copy shadows the outer one", func() {
+				err = gf.CheckImports()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("github.com/ugorji/go/codec in vendor/github.com/gin-gonic/gin/vendor hides vendor"))
+			})
+		})
+	})
+
+	Describe("CheckAdvisories", func() {
+		var (
+			mainPackagePath   string
+			advisoryDir       string
+			oldGoAdvisoryDB   string
+			oldGoAdvisoryFail string
+			goAdvisoryFail    string
+		)
+
+		BeforeEach(func() {
+			mainPackageName = "a/package/name"
+			vendorTool = "go_nativevendoring"
+			goVersion = "1.9.2"
+			goAdvisoryFail = ""
+
+			goPath, err = ioutil.TempDir("", "go-buildpack.package")
+			Expect(err).To(BeNil())
+
+			mainPackagePath = filepath.Join(goPath, "src", mainPackageName)
+			err = os.MkdirAll(filepath.Join(mainPackagePath, "vendor"), 0755)
+			Expect(err).To(BeNil())
+
+			err = ioutil.WriteFile(filepath.Join(mainPackagePath, "vendor", "modules.txt"), []byte(`# github.com/gin-gonic/gin v1.4.0
+github.com/gin-gonic/gin
+# github.com/pkg/errors v0.8.0
+github.com/pkg/errors
+`), 0644)
+			Expect(err).To(BeNil())
+
+			advisoryDir, err = ioutil.TempDir("", "go-buildpack.advisories")
+			Expect(err).To(BeNil())
+
+			err = ioutil.WriteFile(filepath.Join(advisoryDir, "GO-2020-0001.json"), []byte(`{
+  "id": "GO-2020-0001",
+  "summary": "Arbitrary log line injection",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/gin-gonic/gin"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.6.0"}]}]
+  }],
+  "database_specific": {"severity": "MODERATE"}
+}`), 0644)
+			Expect(err).To(BeNil())
+
+			err = ioutil.WriteFile(filepath.Join(advisoryDir, "stdlib.json"), []byte(`[{
+  "id": "GO-2017-0002",
+  "summary": "Toolchain issue",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "stdlib"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "1.9.0"}, {"fixed": "1.9.3"}]}]
+  }],
+  "database_specific": {"severity": "HIGH"}
+}, {
+  "id": "GO-2019-0003",
+  "summary": "Not affected",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/pkg/errors"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0.9.0"}]}]
+  }]
+}, {
+  "id": "GHSA-0000-0004",
+  "summary": "Scored by CVSS only",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/pkg/errors"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "0.8.1"}]}]
+  }],
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}]
+}, {
+  "id": "GHSA-0000-0005",
+  "summary": "Not rated",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/pkg/errors"},
+    "versions": ["v0.8.0"]
+  }]
+}]`), 0644)
+			Expect(err).To(BeNil())
+
+			oldGoAdvisoryDB = os.Getenv("GO_ADVISORY_DB")
+			oldGoAdvisoryFail = os.Getenv("GO_ADVISORY_FAIL_SEVERITY")
+		})
+
+		JustBeforeEach(func() {
+			err = os.Setenv("GO_ADVISORY_DB", advisoryDir)
+			Expect(err).To(BeNil())
+			err = os.Setenv("GO_ADVISORY_FAIL_SEVERITY", goAdvisoryFail)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			err = os.Setenv("GO_ADVISORY_DB", oldGoAdvisoryDB)
+			Expect(err).To(BeNil())
+			err = os.Setenv("GO_ADVISORY_FAIL_SEVERITY", oldGoAdvisoryFail)
+			Expect(err).To(BeNil())
+
+			err = os.RemoveAll(goPath)
+			Expect(err).To(BeNil())
+			err = os.RemoveAll(advisoryDir)
+			Expect(err).To(BeNil())
+		})
+
+		It("warns about vulnerable dependencies and toolchains", func() {
+			err = gf.CheckAdvisories()
+			Expect(err).To(BeNil())
+
+			Expect(buffer.String()).To(ContainSubstring("-----> Checking dependencies against advisory database " + advisoryDir))
+			Expect(buffer.String()).To(ContainSubstring("**WARNING** GO-2020-0001 (MODERATE) github.com/gin-gonic/gin v1.4.0: Arbitrary log line injection; fixed in 1.6.0"))
+			Expect(buffer.String()).To(ContainSubstring("**WARNING** GO-2017-0002 (HIGH) stdlib v1.9.2: Toolchain issue; fixed in 1.9.3"))
+			Expect(buffer.String()).NotTo(ContainSubstring("GO-2019-0003"))
+		})
+
+		It("rates advisories without a severity name by their CVSS vector", func() {
+			err = gf.CheckAdvisories()
+			Expect(err).To(BeNil())
+
+			Expect(buffer.String()).To(ContainSubstring("**WARNING** GHSA-0000-0004 (CRITICAL) github.com/pkg/errors v0.8.0: Scored by CVSS only; fixed in 0.8.1"))
+			Expect(buffer.String()).To(ContainSubstring("**WARNING** GHSA-0000-0005 (UNKNOWN) github.com/pkg/errors v0.8.0: Not rated"))
+		})
+
+		Context("GO_ADVISORY_FAIL_SEVERITY is below the worst finding", func() {
+			BeforeEach(func() {
+				goAdvisoryFail = "high"
+			})
+
+			It("returns an error naming the advisories at or above it", func() {
+				err = gf.CheckAdvisories()
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("**ERROR** Dependencies match advisories at or above $GO_ADVISORY_FAIL_SEVERITY (high):"))
+				Expect(buffer.String()).To(ContainSubstring("GO-2017-0002 in stdlib v1.9.2"))
+				Expect(buffer.String()).To(ContainSubstring("GHSA-0000-0004 in github.com/pkg/errors v0.8.0"))
+				Expect(buffer.String()).NotTo(ContainSubstring("GO-2020-0001 in"))
+			})
+
+			It("says which advisories it could not check", func() {
+				err = gf.CheckAdvisories()
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("GHSA-0000-0005 has no severity, so $GO_ADVISORY_FAIL_SEVERITY was not checked for it"))
+				Expect(buffer.String()).NotTo(ContainSubstring("GHSA-0000-0005 in"))
+			})
+		})
+
+		Context("GO_ADVISORY_FAIL_SEVERITY is above the worst finding", func() {
+			BeforeEach(func() {
+				err = os.Remove(filepath.Join(advisoryDir, "stdlib.json"))
+				Expect(err).To(BeNil())
+				goAdvisoryFail = "critical"
+			})
+
+			It("only warns", func() {
+				err = gf.CheckAdvisories()
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("no advisory database is configured or shipped", func() {
+			BeforeEach(func() {
+				advisoryDir = ""
+			})
+
+			It("skips the check", func() {
+				err = gf.CheckAdvisories()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(Equal(""))
+			})
+		})
+	})
+
+	Describe("SplitDebugSymbols", func() {
+		var (
+			oldSplit      string
+			oldSymbolsDir string
+			symbolsDir    string
+		)
+
+		BeforeEach(func() {
+			oldSplit = os.Getenv("GO_SPLIT_DEBUG_SYMBOLS")
+			oldSymbolsDir = os.Getenv("GO_DEBUG_SYMBOLS_DIR")
+
+			symbolsDir, err = ioutil.TempDir("", "go-buildpack.symbols.")
+			Expect(err).To(BeNil())
+
+			err = os.MkdirAll(filepath.Join(buildDir, "bin"), 0755)
+			Expect(err).To(BeNil())
+
+			// the test binary is a real Go executable with a build ID
+			err = libbuildpack.CopyFile(os.Args[0], filepath.Join(buildDir, "bin", "app"))
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("GO_SPLIT_DEBUG_SYMBOLS", oldSplit)).To(Succeed())
+			Expect(os.Setenv("GO_DEBUG_SYMBOLS_DIR", oldSymbolsDir)).To(Succeed())
+			Expect(os.RemoveAll(symbolsDir)).To(Succeed())
+		})
+
+		Context("GO_SPLIT_DEBUG_SYMBOLS is not set", func() {
+			BeforeEach(func() {
+				Expect(os.Unsetenv("GO_SPLIT_DEBUG_SYMBOLS")).To(Succeed())
+			})
+
+			It("does not touch the binaries", func() {
+				err = gf.SplitDebugSymbols()
+				Expect(err).To(BeNil())
+
+				Expect(gf.DebugSymbols).To(BeEmpty())
+			})
+		})
+
+		Context("GO_SPLIT_DEBUG_SYMBOLS is true", func() {
+			var binary string
+
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_SPLIT_DEBUG_SYMBOLS", "true")).To(Succeed())
+				Expect(os.Setenv("GO_DEBUG_SYMBOLS_DIR", symbolsDir)).To(Succeed())
+
+				binary = filepath.Join(buildDir, "bin", "app")
+			})
+
+			It("saves the DWARF by build ID and strips the binary", func() {
+				var debugFile string
+				mockCommand.EXPECT().Execute(filepath.Join(buildDir, "bin"), gomock.Any(), gomock.Any(), "objcopy", "--only-keep-debug", binary, gomock.Any()).Do(func(_ string, _, _ io.Writer, _ string, args ...string) {
+					debugFile = args[2]
+				}).Return(nil)
+				mockCommand.EXPECT().Execute(filepath.Join(buildDir, "bin"), gomock.Any(), gomock.Any(), "objcopy", "--strip-all", gomock.Any(), binary).Return(nil)
+
+				err = gf.SplitDebugSymbols()
+				Expect(err).To(BeNil())
+
+				Expect(gf.DebugSymbols).To(HaveLen(1))
+				Expect(gf.DebugSymbols[0].Binary).To(Equal("bin/app"))
+				Expect(gf.DebugSymbols[0].BuildID).NotTo(BeEmpty())
+				Expect(gf.DebugSymbols[0].File).To(Equal(debugFile))
+				Expect(filepath.Dir(debugFile)).To(Equal(symbolsDir))
+				Expect(debugFile).To(HaveSuffix(".debug"))
+			})
+
+			It("maps build IDs to debug files", func() {
+				mockCommand.EXPECT().Execute(gomock.Any(), gomock.Any(), gomock.Any(), "objcopy", gomock.Any()).Return(nil).Times(2)
+
+				err = gf.SplitDebugSymbols()
+				Expect(err).To(BeNil())
+
+				mapping := map[string]string{}
+				err = libbuildpack.NewJSON().Load(filepath.Join(symbolsDir, "build-ids.json"), &mapping)
+				Expect(err).To(BeNil())
+				Expect(mapping).To(HaveKeyWithValue(gf.DebugSymbols[0].BuildID, filepath.Base(gf.DebugSymbols[0].File)))
+			})
+
+			It("keeps the symbols of earlier builds in GO_DEBUG_SYMBOLS_DIR", func() {
+				Expect(ioutil.WriteFile(filepath.Join(symbolsDir, "earlier.debug"), []byte("dwarf"), 0644)).To(Succeed())
+				Expect(libbuildpack.NewJSON().Write(filepath.Join(symbolsDir, "build-ids.json"), map[string]string{"earlier": "earlier.debug"})).To(Succeed())
+				mockCommand.EXPECT().Execute(gomock.Any(), gomock.Any(), gomock.Any(), "objcopy", gomock.Any()).Return(nil).Times(2)
+
+				err = gf.SplitDebugSymbols()
+				Expect(err).To(BeNil())
+
+				Expect(filepath.Join(symbolsDir, "earlier.debug")).To(BeARegularFile())
+				mapping := map[string]string{}
+				Expect(libbuildpack.NewJSON().Load(filepath.Join(symbolsDir, "build-ids.json"), &mapping)).To(Succeed())
+				Expect(mapping).To(HaveKeyWithValue("earlier", "earlier.debug"))
+				Expect(mapping).To(HaveLen(2))
+			})
+
+			Context("GO_DEBUG_SYMBOLS_DIR is not set", func() {
+				var (
+					cacheDir       string
+					cacheSymbols   string
+					currentSymbols string
+				)
+
+				BeforeEach(func() {
+					Expect(os.Unsetenv("GO_DEBUG_SYMBOLS_DIR")).To(Succeed())
+
+					cacheDir, err = ioutil.TempDir("", "go-buildpack.cache.")
+					Expect(err).To(BeNil())
+					cacheSymbols = filepath.Join(cacheDir, "go-debug-symbols")
+					Expect(os.MkdirAll(cacheSymbols, 0755)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(cacheSymbols, "earlier.debug"), []byte("dwarf"), 0644)).To(Succeed())
+					Expect(libbuildpack.NewJSON().Write(filepath.Join(cacheSymbols, "build-ids.json"), map[string]string{"earlier": "earlier.debug"})).To(Succeed())
+				})
+
+				JustBeforeEach(func() {
+					gf.Stager = libbuildpack.NewStager([]string{buildDir, cacheDir, depsDir, depsIdx}, logger, &libbuildpack.Manifest{})
+				})
+
+				AfterEach(func() {
+					Expect(os.RemoveAll(cacheDir)).To(Succeed())
+				})
+
+				It("keeps only the current build's symbols in the app cache", func() {
+					mockCommand.EXPECT().Execute(gomock.Any(), gomock.Any(), gomock.Any(), "objcopy", "--only-keep-debug", binary, gomock.Any()).Do(func(_ string, _, _ io.Writer, _ string, args ...string) {
+						currentSymbols = args[2]
+						Expect(ioutil.WriteFile(currentSymbols, []byte("dwarf"), 0644)).To(Succeed())
+					}).Return(nil)
+					mockCommand.EXPECT().Execute(gomock.Any(), gomock.Any(), gomock.Any(), "objcopy", "--strip-all", gomock.Any(), binary).Return(nil)
+
+					err = gf.SplitDebugSymbols()
+					Expect(err).To(BeNil())
+
+					Expect(filepath.Dir(currentSymbols)).To(Equal(cacheSymbols))
+					Expect(currentSymbols).To(BeARegularFile())
+					Expect(filepath.Join(cacheSymbols, "earlier.debug")).NotTo(BeAnExistingFile())
+
+					mapping := map[string]string{}
+					Expect(libbuildpack.NewJSON().Load(filepath.Join(cacheSymbols, "build-ids.json"), &mapping)).To(Succeed())
+					Expect(mapping).To(Equal(map[string]string{gf.DebugSymbols[0].BuildID: filepath.Base(currentSymbols)}))
+				})
+			})
+
+			Context("a binary is not an ELF executable", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(binary, []byte("#!/bin/sh"), 0755)
+					Expect(err).To(BeNil())
+				})
+
+				It("returns an error", func() {
+					err = gf.SplitDebugSymbols()
+					Expect(err).NotTo(BeNil())
+					Expect(err.Error()).To(HavePrefix("app: "))
+				})
+			})
+		})
+	})
+
+	Describe("PruneDroplet", func() {
+		var oldPrune, oldInclude string
+
+		BeforeEach(func() {
+			oldPrune = os.Getenv("GO_PRUNE_DROPLET")
+			oldInclude = os.Getenv("GO_DROPLET_INCLUDE")
+
+			for _, file := range []string{"bin/app", "Procfile", ".profile.d/go.sh", "main.go", "vendor/a/b.go", "templates/index.html", "assets/css/site.css", "assets/src/site.scss"} {
+				err = os.MkdirAll(filepath.Join(buildDir, filepath.Dir(file)), 0755)
+				Expect(err).To(BeNil())
+				err = ioutil.WriteFile(filepath.Join(buildDir, file), []byte("xxxx"), 0644)
+				Expect(err).To(BeNil())
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("GO_PRUNE_DROPLET", oldPrune)).To(Succeed())
+			Expect(os.Setenv("GO_DROPLET_INCLUDE", oldInclude)).To(Succeed())
+		})
+
+		Context("GO_PRUNE_DROPLET is not set", func() {
+			BeforeEach(func() {
+				Expect(os.Unsetenv("GO_PRUNE_DROPLET")).To(Succeed())
+			})
+
+			It("leaves the build dir alone", func() {
+				err = gf.PruneDroplet()
+				Expect(err).To(BeNil())
+
+				Expect(filepath.Join(buildDir, "main.go")).To(BeARegularFile())
+			})
+		})
+
+		Context("GO_PRUNE_DROPLET is true", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("GO_PRUNE_DROPLET", "true")).To(Succeed())
+				Expect(os.Setenv("GO_DROPLET_INCLUDE", "templates,assets/css")).To(Succeed())
+			})
+
+			It("keeps what the app needs at runtime", func() {
+				err = gf.PruneDroplet()
+				Expect(err).To(BeNil())
+
+				Expect(filepath.Join(buildDir, "bin", "app")).To(BeARegularFile())
+				Expect(filepath.Join(buildDir, "Procfile")).To(BeARegularFile())
+				Expect(filepath.Join(buildDir, ".profile.d", "go.sh")).To(BeARegularFile())
+				Expect(filepath.Join(buildDir, "templates", "index.html")).To(BeARegularFile())
+				Expect(filepath.Join(buildDir, "assets", "css", "site.css")).To(BeARegularFile())
+			})
+
+			It("removes everything else", func() {
+				err = gf.PruneDroplet()
+				Expect(err).To(BeNil())
+
+				Expect(filepath.Join(buildDir, "main.go")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(buildDir, "vendor")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(buildDir, "assets", "src")).NotTo(BeAnExistingFile())
+			})
+
+			It("logs how many bytes were saved", func() {
+				err = gf.PruneDroplet()
+				Expect(err).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("-----> Pruning droplet, keeping bin Procfile .profile .profile.d .cloudfoundry templates assets/css"))
+				Expect(buffer.String()).To(ContainSubstring("Removed 12 bytes of source and build-only files"))
+			})
+		})
+	})
+
+	Describe("WriteSBOM", func() {
+		var mainPackagePath string
+
+		type sbomDoc struct {
+			BOMFormat string `json:"bomFormat"`
+			Metadata  struct {
+				Tools []struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				} `json:"tools"`
+				Component struct {
+					Name string `json:"name"`
+				} `json:"component"`
+				Properties []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"properties"`
+			} `json:"metadata"`
+			Components []struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+				PURL    string `json:"purl"`
+			} `json:"components"`
+		}
+
+		readSBOM := func() sbomDoc {
+			var doc sbomDoc
+			err := libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-sbom.cdx.json"), &doc)
+			Expect(err).To(BeNil())
+			return doc
+		}
+
+		BeforeEach(func() {
+			mainPackageName = "a/package/name"
+			goVersion = "1.9.2"
+			goPath, err = ioutil.TempDir("", "go-buildpack.package")
+			Expect(err).To(BeNil())
+
+			mainPackagePath = filepath.Join(goPath, "src", mainPackageName)
+			err = os.MkdirAll(mainPackagePath, 0755)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			err = os.RemoveAll(goPath)
+			Expect(err).To(BeNil())
+		})
+
+		JustBeforeEach(func() {
+			gf.BuildpackVersion = "1.8.14"
+		})
+
+		Context("the vendor tool is godep", func() {
+			BeforeEach(func() {
+				vendorTool = "godep"
+				godepConfig = godep.Godep{
+					ImportPath: "go-online",
+					GoVersion:  "go1.9",
+					Deps: []godep.Dependency{
+						{ImportPath: "github.com/a/tagged", Comment: "v1.2.0", Rev: "abc123"},
+						{ImportPath: "github.com/b/untagged", Rev: "def456"},
+					},
+				}
+			})
+
+			It("lists the toolchain and each dependency from Godeps.json", func() {
+				err = gf.WriteSBOM()
+				Expect(err).To(BeNil())
 
-				Context("vendor experiment is true", func() {
-					BeforeEach(func() {
-						vendorExperiment = true
-					})
+				doc := readSBOM()
+				Expect(doc.BOMFormat).To(Equal("CycloneDX"))
+				Expect(doc.Metadata.Component.Name).To(Equal("a/package/name"))
+				Expect(doc.Metadata.Tools[0].Name).To(Equal("go-buildpack"))
+				Expect(doc.Metadata.Tools[0].Version).To(Equal("1.8.14"))
+				Expect(doc.Metadata.Properties[0].Value).To(Equal("godep"))
 
-					It("does not wrap the install command with godep", func() {
-						mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+				Expect(doc.Components).To(HaveLen(3))
+				Expect(doc.Components[0].Name).To(Equal("go"))
+				Expect(doc.Components[0].Version).To(Equal("1.9.2"))
+				Expect(doc.Components[1].Name).To(Equal("github.com/a/tagged"))
+				Expect(doc.Components[1].Version).To(Equal("v1.2.0"))
+				Expect(doc.Components[1].PURL).To(Equal("pkg:golang/github.com/a/tagged@v1.2.0"))
+				Expect(doc.Components[2].Version).To(Equal("def456"))
+			})
 
-						err = gf.CompileApp()
-						Expect(err).To(BeNil())
+			It("logs a summary", func() {
+				err = gf.WriteSBOM()
+				Expect(err).To(BeNil())
 
-						Expect(buffer.String()).To(ContainSubstring("-----> Running: go install -a=1 -b=2 first second"))
-					})
+				Expect(buffer.String()).To(ContainSubstring("-----> Writing software bill of materials to .cloudfoundry/go-sbom.cdx.json"))
+				Expect(buffer.String()).To(ContainSubstring("Go toolchain: 1.9.2"))
+				Expect(buffer.String()).To(ContainSubstring("Vendor tool: godep"))
+				Expect(buffer.String()).To(ContainSubstring("Dependencies: 2 (from Godeps/Godeps.json)"))
+			})
+		})
+
+		Context("the vendor tool is dep", func() {
+			BeforeEach(func() {
+				vendorTool = "dep"
+				err = ioutil.WriteFile(filepath.Join(mainPackagePath, "Gopkg.lock"), []byte(`# This file is autogenerated, do not edit; changes may be undone by the next 'dep ensure'.
+
+[[projects]]
+  name = "github.com/gorilla/mux"
+  packages = ["."]
+  revision = "7f08801859139f86dfafd1c296e2cba9a80d292e"
+  version = "v1.6.0"
+
+[[projects]]
+  branch = "master"
+  name = "golang.org/x/net"
+  packages = [
+    "context",
+  ]
+  revision = "a8b9294777976932365dabb6640cf1468d95c70f"
+
+[solve-meta]
+  analyzer-name = "dep"
+  inputs-digest = "1234"
+`), 0644)
+				Expect(err).To(BeNil())
+			})
+
+			It("lists each project from Gopkg.lock", func() {
+				err = gf.WriteSBOM()
+				Expect(err).To(BeNil())
+
+				doc := readSBOM()
+				Expect(doc.Components).To(HaveLen(3))
+				Expect(doc.Components[1].Name).To(Equal("github.com/gorilla/mux"))
+				Expect(doc.Components[1].Version).To(Equal("v1.6.0"))
+				Expect(doc.Components[2].Name).To(Equal("golang.org/x/net"))
+				Expect(doc.Components[2].Version).To(Equal("a8b9294777976932365dabb6640cf1468d95c70f"))
+			})
+		})
+
+		Context("the vendor tool is glide", func() {
+			BeforeEach(func() {
+				vendorTool = "glide"
+				err = ioutil.WriteFile(filepath.Join(mainPackagePath, "glide.lock"), []byte(`hash: 77b39ff0b1b7bc865505971851c3c60476276e186b961736e9fcd9204cdc54ba
+imports:
+- name: github.com/ZiCog/shiny-thing
+  version: e9e19444ccf5362bba846441c4700a49a94b8118
+  subpackages:
+  - foo
+devImports: []
+`), 0644)
+				Expect(err).To(BeNil())
+			})
+
+			It("lists each import from glide.lock", func() {
+				err = gf.WriteSBOM()
+				Expect(err).To(BeNil())
+
+				doc := readSBOM()
+				Expect(doc.Components).To(HaveLen(2))
+				Expect(doc.Components[1].Name).To(Equal("github.com/ZiCog/shiny-thing"))
+				Expect(doc.Components[1].Version).To(Equal("e9e19444ccf5362bba846441c4700a49a94b8118"))
+			})
+		})
+
+		Context("the vendor tool is go_nativevendoring", func() {
+			BeforeEach(func() {
+				vendorTool = "go_nativevendoring"
+			})
+
+			Context("there is a go.mod", func() {
+				BeforeEach(func() {
+					err = ioutil.WriteFile(filepath.Join(mainPackagePath, "go.mod"), []byte(`module a/package/name
 
+require github.com/pkg/errors v0.8.0
+
+require (
+	github.com/gorilla/mux v1.6.0 // indirect
+)
+`), 0644)
+					Expect(err).To(BeNil())
 				})
 
-				Context("vendor experiment is false", func() {
-					BeforeEach(func() {
-						vendorExperiment = false
-					})
+				It("lists each requirement", func() {
+					err = gf.WriteSBOM()
+					Expect(err).To(BeNil())
 
-					It("logs and runs the install command wrapped with godep", func() {
-						mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "godep", "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+					doc := readSBOM()
+					Expect(doc.Components).To(HaveLen(3))
+					Expect(doc.Components[1].PURL).To(Equal("pkg:golang/github.com/pkg/errors@v0.8.0"))
+					Expect(doc.Components[2].PURL).To(Equal("pkg:golang/github.com/gorilla/mux@v1.6.0"))
+				})
+			})
 
-						err = gf.CompileApp()
-						Expect(err).To(BeNil())
+			Context("there is a vendor/vendor.json", func() {
+				BeforeEach(func() {
+					err = os.MkdirAll(filepath.Join(mainPackagePath, "vendor"), 0755)
+					Expect(err).To(BeNil())
+					err = ioutil.WriteFile(filepath.Join(mainPackagePath, "vendor", "vendor.json"), []byte(`{"package":[{"path":"github.com/pkg/errors","revision":"645ef00459ed84a119197bfb8d8205042c6df63d","versionExact":"v0.8.0"}]}`), 0644)
+					Expect(err).To(BeNil())
+				})
 
-						Expect(buffer.String()).To(ContainSubstring("-----> Running: godep go install -a=1 -b=2 first second"))
-					})
+				It("lists each vendored package", func() {
+					err = gf.WriteSBOM()
+					Expect(err).To(BeNil())
+
+					doc := readSBOM()
+					Expect(doc.Components).To(HaveLen(2))
+					Expect(doc.Components[1].Version).To(Equal("v0.8.0"))
+				})
+			})
+
+			Context("no dependencies are recorded", func() {
+				It("lists only the toolchain", func() {
+					err = gf.WriteSBOM()
+					Expect(err).To(BeNil())
+
+					doc := readSBOM()
+					Expect(doc.Components).To(HaveLen(1))
+					Expect(buffer.String()).To(ContainSubstring("Dependencies: none recorded"))
 				})
 			})
 		})
+	})
 
-		AssertLogsAndRunsGenericInstallCommand := func() {
-			It("logs and runs the install command it is going to run", func() {
-				mockCommand.EXPECT().Execute(mainPackagePath, gomock.Any(), gomock.Any(), "go", "install", "-a=1", "-b=2", "first", "second").Return(nil)
+	Describe("RunPlan", func() {
+		var plan finalize.StagingPlan
 
-				err = gf.CompileApp()
-				Expect(err).To(BeNil())
+		BeforeEach(func() {
+			vendorTool = "dep"
+			goVersion = "1.9.2"
+			mainPackageName = "a/package/name"
 
-				Expect(buffer.String()).To(ContainSubstring("-----> Running: go install -a=1 -b=2 first second"))
-			})
-		}
+			err = os.MkdirAll(filepath.Join(buildDir, "vendor", "a", "dependency"), 0755)
+			Expect(err).To(BeNil())
+		})
 
-		Context("the tool is glide", func() {
+		JustBeforeEach(func() {
+			gf.PlanOnly = true
+			gf.VendorToolReason = "found Gopkg.toml"
+			gf.GoVersionSource = "buildpack default (go1.9.2)"
+		})
+
+		It("writes the staging plan and stops before compiling", func() {
+			err = finalize.RunPlan(gf)
+			Expect(err).To(Equal(finalize.ErrPlanOnly))
+
+			err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-staging-plan.json"), &plan)
+			Expect(err).To(BeNil())
+
+			Expect(plan.VendorTool).To(Equal("dep"))
+			Expect(plan.VendorToolReason).To(Equal("found Gopkg.toml"))
+			Expect(plan.GoVersion).To(Equal("1.9.2"))
+			Expect(plan.GoVersionSource).To(Equal("buildpack default (go1.9.2)"))
+			Expect(plan.MainPackage).To(Equal("a/package/name"))
+			Expect(plan.VendorStep).To(Equal("skipped, vendor directory is not empty"))
+			Expect(plan.Packages).To(Equal([]string{"."}))
+			Expect(plan.CompileCommand).To(Equal([]string{"go", "install", "-tags", "cloudfoundry", "-buildmode", "pie", "."}))
+		})
+
+		It("logs the plan", func() {
+			err = finalize.RunPlan(gf)
+			Expect(err).To(Equal(finalize.ErrPlanOnly))
+
+			Expect(buffer.String()).To(ContainSubstring("-----> Writing staging plan to .cloudfoundry/go-staging-plan.json"))
+			Expect(buffer.String()).To(ContainSubstring("Vendor tool: dep (found Gopkg.toml)"))
+			Expect(buffer.String()).To(ContainSubstring("Compile command: go install -tags cloudfoundry -buildmode pie ."))
+			Expect(buffer.String()).To(ContainSubstring("Unset $GO_STAGING_PLAN to stage the app."))
+		})
+
+		It("does not modify the app", func() {
+			err = finalize.RunPlan(gf)
+			Expect(err).To(Equal(finalize.ErrPlanOnly))
+
+			Expect(filepath.Join(buildDir, "bin")).NotTo(BeADirectory())
+			Expect(filepath.Join(buildDir, "vendor", "a", "dependency")).To(BeADirectory())
+		})
+
+		Context("the main packages are under cmd/", func() {
 			BeforeEach(func() {
-				vendorTool = "glide"
+				for _, name := range []string{"web", "worker"} {
+					Expect(os.MkdirAll(filepath.Join(buildDir, "cmd", name), 0755)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(buildDir, "cmd", name, "main.go"), []byte("package main\n"), 0644)).To(Succeed())
+				}
+			})
+
+			It("plans to install them, as staging would", func() {
+				err = finalize.RunPlan(gf)
+				Expect(err).To(Equal(finalize.ErrPlanOnly))
+
+				err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-staging-plan.json"), &plan)
+				Expect(err).To(BeNil())
+
+				Expect(plan.Packages).To(Equal([]string{"./cmd/web", "./cmd/worker"}))
 			})
-			AssertLogsAndRunsGenericInstallCommand()
 		})
 
-		Context("the tool is dep", func() {
+		Context("GO_MIGRATE_GODEPS_WORKSPACE is set for an app with a Godeps workspace", func() {
+			var oldMigrate string
+
 			BeforeEach(func() {
-				vendorTool = "dep"
+				oldMigrate = os.Getenv("GO_MIGRATE_GODEPS_WORKSPACE")
+				Expect(os.Setenv("GO_MIGRATE_GODEPS_WORKSPACE", "true")).To(Succeed())
+
+				vendorTool = "godep"
+				godepConfig = godep.Godep{ImportPath: mainPackageName, WorkspaceExists: true, Packages: []string{"."}}
+
+				err = os.MkdirAll(filepath.Join(buildDir, "Godeps", "_workspace", "src", "github.com", "dep", "a"), 0755)
+				Expect(err).To(BeNil())
 			})
-			AssertLogsAndRunsGenericInstallCommand()
+
+			AfterEach(func() {
+				Expect(os.Setenv("GO_MIGRATE_GODEPS_WORKSPACE", oldMigrate)).To(Succeed())
+				godepConfig = godep.Godep{}
+			})
+
+			It("plans the build the migration leads to, without migrating", func() {
+				err = finalize.RunPlan(gf)
+				Expect(err).To(Equal(finalize.ErrPlanOnly))
+
+				err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-staging-plan.json"), &plan)
+				Expect(err).To(BeNil())
+
+				Expect(plan.GodepsWorkspace).To(Equal("migrated to vendor/ by $GO_MIGRATE_GODEPS_WORKSPACE"))
+				Expect(plan.CompileCommand).To(Equal([]string{"go", "install", "-tags", "cloudfoundry", "-buildmode", "pie", "."}))
+				Expect(buffer.String()).To(ContainSubstring("Godeps/_workspace: migrated to vendor/ by $GO_MIGRATE_GODEPS_WORKSPACE"))
+				Expect(filepath.Join(buildDir, "Godeps", "_workspace", "src", "github.com", "dep", "a")).To(BeADirectory())
+			})
+		})
+	})
+
+	Describe("WriteBuildReport", func() {
+		type buildReport struct {
+			BuildpackVersion string   `json:"buildpack_version"`
+			GoVersion        string   `json:"go_version"`
+			VendorTool       string   `json:"vendor_tool"`
+			MainPackage      string   `json:"main_package"`
+			Packages         []string `json:"packages"`
+			BuildFlags       []string `json:"build_flags"`
+			LinkerSymbol     string   `json:"linker_symbol"`
+			StepDurations    []struct {
+				Step    string  `json:"step"`
+				Seconds float64 `json:"seconds"`
+			} `json:"step_durations"`
+			Binaries []struct {
+				Path   string `json:"path"`
+				SHA256 string `json:"sha256"`
+			} `json:"binaries"`
+			DebugSymbols []finalize.DebugSymbols `json:"debug_symbols"`
+		}
+
+		BeforeEach(func() {
+			vendorTool = "dep"
+			goVersion = "1.9.2"
+			mainPackageName = "a/package/name"
+			packageList = []string{"."}
+			buildFlags = []string{"-tags", "cloudfoundry"}
+
+			err = os.MkdirAll(filepath.Join(buildDir, "bin"), 0755)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(buildDir, "bin", "name"), []byte("binary"), 0755)
+			Expect(err).To(BeNil())
 		})
 
-		Context("the tool is go_nativevendoring", func() {
+		JustBeforeEach(func() {
+			gf.BuildpackVersion = "1.8.14"
+			gf.StepDurations = []finalize.StepDuration{{Step: "compile", Seconds: 2.5}}
+		})
+
+		It("writes what produced the binaries into the droplet", func() {
+			err = gf.WriteBuildReport()
+			Expect(err).To(BeNil())
+
+			var report buildReport
+			err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-build-report.json"), &report)
+			Expect(err).To(BeNil())
+
+			Expect(report.BuildpackVersion).To(Equal("1.8.14"))
+			Expect(report.GoVersion).To(Equal("1.9.2"))
+			Expect(report.VendorTool).To(Equal("dep"))
+			Expect(report.MainPackage).To(Equal("a/package/name"))
+			Expect(report.Packages).To(Equal([]string{"."}))
+			Expect(report.BuildFlags).To(Equal([]string{"-tags", "cloudfoundry"}))
+			Expect(report.StepDurations).To(HaveLen(1))
+			Expect(report.StepDurations[0].Step).To(Equal("compile"))
+			Expect(report.StepDurations[0].Seconds).To(Equal(2.5))
+			Expect(report.Binaries).To(HaveLen(1))
+			Expect(report.Binaries[0].Path).To(Equal("bin/name"))
+			Expect(report.Binaries[0].SHA256).To(Equal("9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd"))
+		})
+
+		It("records where split debug symbols were saved", func() {
+			gf.DebugSymbols = []finalize.DebugSymbols{{Binary: "bin/name", BuildID: "abc123", File: "/cache/go-debug-symbols/abc123.debug"}}
+
+			err = gf.WriteBuildReport()
+			Expect(err).To(BeNil())
+
+			var report buildReport
+			err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-build-report.json"), &report)
+			Expect(err).To(BeNil())
+
+			Expect(report.DebugSymbols).To(Equal(gf.DebugSymbols))
+		})
+
+		It("writes the go-build-report.sh script to <depDir>/profile.d", func() {
+			err = gf.WriteBuildReport()
+			Expect(err).To(BeNil())
+
+			contents, err := ioutil.ReadFile(filepath.Join(gf.Stager.DepDir(), "profile.d", "go-build-report.sh"))
+			Expect(err).To(BeNil())
+
+			Expect(string(contents)).To(Equal("export GO_BUILD_REPORT=$HOME/.cloudfoundry/go-build-report.json\n"))
+		})
+
+		Context("link environment variables are set", func() {
+			var (
+				oldGoLinkerSymbol string
+				oldGoLinkerValue  string
+			)
+
 			BeforeEach(func() {
-				vendorTool = "go_nativevendoring"
+				oldGoLinkerSymbol = os.Getenv("GO_LINKER_SYMBOL")
+				oldGoLinkerValue = os.Getenv("GO_LINKER_VALUE")
+
+				err = os.Setenv("GO_LINKER_SYMBOL", "main.version")
+				Expect(err).To(BeNil())
+				err = os.Setenv("GO_LINKER_VALUE", "1.0.0")
+				Expect(err).To(BeNil())
+			})
+
+			AfterEach(func() {
+				err = os.Setenv("GO_LINKER_SYMBOL", oldGoLinkerSymbol)
+				Expect(err).To(BeNil())
+				err = os.Setenv("GO_LINKER_VALUE", oldGoLinkerValue)
+				Expect(err).To(BeNil())
+			})
+
+			It("records the linker symbol", func() {
+				err = gf.WriteBuildReport()
+				Expect(err).To(BeNil())
+
+				var report buildReport
+				err = libbuildpack.NewJSON().Load(filepath.Join(buildDir, ".cloudfoundry", "go-build-report.json"), &report)
+				Expect(err).To(BeNil())
+				Expect(report.LinkerSymbol).To(Equal("main.version"))
 			})
-			AssertLogsAndRunsGenericInstallCommand()
 		})
 	})
 
@@ -1075,6 +2882,36 @@ default_process_types:
 			Expect(string(contents)).To(Equal(yaml))
 		})
 
+		Context("the app is built from a subdirectory", func() {
+			JustBeforeEach(func() {
+				gf.PackageSubdir = "apps/api"
+			})
+
+			It("starts the binary of the subdirectory package", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				contents, err := ioutil.ReadFile(filepath.Join(tempDir, "buildpack-release-step.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(contents)).To(ContainSubstring("web: api\n"))
+			})
+		})
+
+		Context("main packages were discovered below the app root", func() {
+			JustBeforeEach(func() {
+				gf.WebProcess = "worker"
+			})
+
+			It("starts the chosen binary", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				contents, err := ioutil.ReadFile(filepath.Join(tempDir, "buildpack-release-step.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(contents)).To(ContainSubstring("web: worker\n"))
+			})
+		})
+
 		It("writes the go.sh script to <depDir>/profile.d", func() {
 			err = gf.CreateStartupEnvironment(tempDir)
 			Expect(err).To(BeNil())
@@ -1085,6 +2922,87 @@ default_process_types:
 			Expect(string(contents)).To(Equal("PATH=$PATH:$HOME/bin\n"))
 		})
 
+		It("does not write go-process-settings.sh without runtime settings", func() {
+			err = gf.CreateStartupEnvironment(tempDir)
+			Expect(err).To(BeNil())
+
+			Expect(filepath.Join(gf.Stager.DepDir(), "profile.d", "go-process-settings.sh")).NotTo(BeAnExistingFile())
+		})
+
+		Context("runtime settings are configured per process type", func() {
+			var runScript = func(env ...string) string {
+				script := filepath.Join(gf.Stager.DepDir(), "profile.d", "go-process-settings.sh")
+				cmd := exec.Command("bash", "-c", "source "+script+" && echo GOGC=$GOGC GOTRACEBACK=$GOTRACEBACK GODEBUG=$GODEBUG")
+				cmd.Env = env
+				output, err := cmd.Output()
+				Expect(err).To(BeNil())
+				return string(output)
+			}
+
+			JustBeforeEach(func() {
+				gf.RuntimeSettings = map[string]map[string]string{
+					"web":    {"GOGC": "200", "GOTRACEBACK": "crash"},
+					"worker": {"GODEBUG": "madvdontneed=1,gctrace='1'"},
+				}
+			})
+
+			It("applies the settings of the launched process type", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				Expect(runScript(`VCAP_APPLICATION={"name":"app","process_type":"web"}`)).To(Equal("GOGC=200 GOTRACEBACK=crash GODEBUG=\n"))
+				Expect(runScript(`VCAP_APPLICATION={"process_type": "worker"}`)).To(Equal("GOGC= GOTRACEBACK= GODEBUG=madvdontneed=1,gctrace='1'\n"))
+				Expect(runScript(`VCAP_APPLICATION={"process_type":"task"}`)).To(Equal("GOGC= GOTRACEBACK= GODEBUG=\n"))
+			})
+
+			It("keeps values set with cf set-env", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				Expect(runScript(`VCAP_APPLICATION={"process_type":"web"}`, "GOGC=off")).To(Equal("GOGC=off GOTRACEBACK=crash GODEBUG=\n"))
+			})
+		})
+
+		Context("writing the go-runtime-limits.sh script", func() {
+			var runScript = func(env ...string) string {
+				script := filepath.Join(gf.Stager.DepDir(), "profile.d", "go-runtime-limits.sh")
+				cmd := exec.Command("bash", "-c", "source "+script+" && echo GOMEMLIMIT=$GOMEMLIMIT GOGC=$GOGC")
+				cmd.Env = env
+				output, err := cmd.Output()
+				Expect(err).To(BeNil())
+				return string(output)
+			}
+
+			It("sets GOMEMLIMIT below MEMORY_LIMIT", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				Expect(runScript("MEMORY_LIMIT=1G")).To(ContainSubstring("GOMEMLIMIT=921MiB "))
+				Expect(runScript("MEMORY_LIMIT=512m")).To(ContainSubstring("GOMEMLIMIT=460MiB "))
+			})
+
+			It("keeps values the user set", func() {
+				err = gf.CreateStartupEnvironment(tempDir)
+				Expect(err).To(BeNil())
+
+				Expect(runScript("MEMORY_LIMIT=1G", "GOMEMLIMIT=200MiB")).To(ContainSubstring("GOMEMLIMIT=200MiB "))
+			})
+
+			Context("the toolchain predates GOMEMLIMIT", func() {
+				JustBeforeEach(func() {
+					gf.GoVersion = "1.9.2"
+				})
+
+				It("lowers GOGC in small containers instead", func() {
+					err = gf.CreateStartupEnvironment(tempDir)
+					Expect(err).To(BeNil())
+
+					Expect(runScript("MEMORY_LIMIT=256M")).To(Equal("GOMEMLIMIT= GOGC=50\n"))
+					Expect(runScript("MEMORY_LIMIT=2G")).To(Equal("GOMEMLIMIT= GOGC=\n"))
+				})
+			})
+		})
+
 		Context("GO_INSTALL_TOOLS_IN_IMAGE is not set", func() {
 			BeforeEach(func() {
 				err = os.MkdirAll(filepath.Join(depsDir, "06", "go3.4.5"), 0755)
@@ -1175,6 +3093,25 @@ default_process_types:
 				Expect(string(contents)).To(ContainSubstring("export GOPATH=$HOME"))
 				Expect(string(contents)).To(ContainSubstring("cd $GOPATH/src/" + mainPackageName))
 			})
+
+			Context("the main package is nested", func() {
+				BeforeEach(func() {
+					mainPackageName = "github.com/org/monorepo"
+				})
+
+				JustBeforeEach(func() {
+					gf.PackageSubdir = "apps/api"
+				})
+
+				It("changes to the full nested package path", func() {
+					err = gf.CreateStartupEnvironment(tempDir)
+					Expect(err).To(BeNil())
+
+					contents, err := ioutil.ReadFile(filepath.Join(gf.Stager.DepDir(), "profile.d", "zzgopath.sh"))
+					Expect(err).To(BeNil())
+					Expect(string(contents)).To(ContainSubstring("cd $GOPATH/src/github.com/org/monorepo/apps/api\n"))
+				})
+			})
 		})
 	})
 
@@ -1,8 +1,15 @@
 package godep
 
 type Godep struct {
-	ImportPath      string   `json:"ImportPath"`
-	GoVersion       string   `json:"GoVersion"`
-	Packages        []string `json:"Packages"`
-	WorkspaceExists bool     `json:"WorkspaceExists"`
+	ImportPath      string       `json:"ImportPath"`
+	GoVersion       string       `json:"GoVersion"`
+	Packages        []string     `json:"Packages"`
+	WorkspaceExists bool         `json:"WorkspaceExists"`
+	Deps            []Dependency `json:"Deps,omitempty"`
+}
+
+type Dependency struct {
+	ImportPath string `json:"ImportPath"`
+	Comment    string `json:"Comment,omitempty"`
+	Rev        string `json:"Rev"`
 }
@@ -0,0 +1,213 @@
+package importpath
+
+import (
+	"bufio"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AmbiguousError is returned when a source names more than one import path
+// for the app.
+type AmbiguousError struct {
+	Source     string
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%s name more than one import path: %s", e.Source, strings.Join(e.Candidates, ", "))
+}
+
+// Infer works out the import path of the app in appDir from, in order, the
+// canonical import comments in its packages, root-package in the
+// [metadata.heroku] table of Gopkg.toml, and the remote in .git/config. It
+// returns the path and a description of where it came from, or "" if no
+// source names one.
+func Infer(appDir string) (string, string, error) {
+	for _, infer := range []func(string) (string, string, error){fromImportComments, fromGopkgToml, fromGitConfig} {
+		importPath, source, err := infer(appDir)
+		if err != nil || importPath != "" {
+			return importPath, source, err
+		}
+	}
+	return "", "", nil
+}
+
+// fromImportComments reads `package x // import "path"` comments. A comment
+// in a subdirectory names the app's path once the subdirectory is trimmed.
+func fromImportComments(appDir string) (string, string, error) {
+	candidates := map[string]string{}
+
+	err := filepath.Walk(appDir, func(dir string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if dir != appDir && (name == "vendor" || name == "testdata" || name == "Godeps" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+
+		pkg, err := build.ImportDir(dir, build.ImportComment)
+		if err != nil && strings.HasPrefix(err.Error(), "found import comments") {
+			return &AmbiguousError{Source: "import comments", Candidates: []string{err.Error()}}
+		}
+		if err != nil || pkg.ImportComment == "" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(appDir, dir)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		root := pkg.ImportComment
+		if rel != "." {
+			if !strings.HasSuffix(root, "/"+rel) {
+				return fmt.Errorf("import comment %q in %s does not end in %s", root, rel, rel)
+			}
+			root = strings.TrimSuffix(root, "/"+rel)
+		}
+		if _, ok := candidates[root]; !ok {
+			candidates[root] = pkg.ImportComment
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", "", nil
+	case 1:
+		for root, comment := range candidates {
+			return root, fmt.Sprintf("import comment %q", comment), nil
+		}
+	}
+	return "", "", &AmbiguousError{Source: "import comments", Candidates: sortedKeys(candidates)}
+}
+
+// fromGopkgToml reads root-package from the [metadata.heroku] table that
+// Heroku's Go buildpack uses.
+func fromGopkgToml(appDir string) (string, string, error) {
+	fh, err := os.Open(filepath.Join(appDir, "Gopkg.toml"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	defer fh.Close()
+
+	table := ""
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+		if table != "metadata.heroku" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "root-package" {
+			return strings.Trim(strings.TrimSpace(parts[1]), `"`), "root-package in Gopkg.toml", nil
+		}
+	}
+	return "", "", scanner.Err()
+}
+
+var (
+	remoteHeader = regexp.MustCompile(`^\[remote "(.+)"\]$`)
+	scpURL       = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+)
+
+// fromGitConfig turns the URL of the origin remote, or of the only remote,
+// into an import path: git@github.com:org/app.git is github.com/org/app.
+func fromGitConfig(appDir string) (string, string, error) {
+	fh, err := os.Open(filepath.Join(appDir, ".git", "config"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	defer fh.Close()
+
+	remotes := map[string]string{}
+	remote := ""
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			remote = ""
+			if m := remoteHeader.FindStringSubmatch(line); m != nil {
+				remote = m[1]
+			}
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if remote != "" && len(parts) == 2 && strings.TrimSpace(parts[0]) == "url" {
+			if importPath := fromRemoteURL(strings.TrimSpace(parts[1])); importPath != "" {
+				remotes[remote] = importPath
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	if importPath, ok := remotes["origin"]; ok {
+		return importPath, "origin remote in .git/config", nil
+	}
+
+	paths := map[string]string{}
+	for name, importPath := range remotes {
+		paths[importPath] = name
+	}
+	switch len(paths) {
+	case 0:
+		return "", "", nil
+	case 1:
+		for importPath, name := range paths {
+			return importPath, fmt.Sprintf("%s remote in .git/config", name), nil
+		}
+	}
+	return "", "", &AmbiguousError{Source: "remotes in .git/config", Candidates: sortedKeys(paths)}
+}
+
+func fromRemoteURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+		if at := strings.Index(url, "@"); at >= 0 && at < strings.Index(url+"/", "/") {
+			url = url[at+1:]
+		}
+		host := strings.SplitN(url, "/", 2)
+		if len(host) != 2 {
+			return ""
+		}
+		return strings.SplitN(host[0], ":", 2)[0] + "/" + host[1]
+	}
+
+	if m := scpURL.FindStringSubmatch(url); m != nil {
+		return m[1] + "/" + strings.TrimPrefix(m[2], "/")
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,298 @@
+package imports
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Import is a package that could not be found, and the app-relative
+// directories of the packages importing it.
+type Import struct {
+	Path       string
+	ImportedBy []string
+}
+
+// Repo groups missing imports by the repository they would come from.
+type Repo struct {
+	Root    string
+	Imports []Import
+}
+
+// Shadow is a package vendored in two vendor directories where the inner one
+// hides the outer one from the code beneath it, so the same package is
+// compiled twice with distinct types.
+type Shadow struct {
+	Path  string
+	Outer string
+	Inner string
+}
+
+// Checker resolves imports the way go install would for an app in AppDir
+// with import path ImportPath: from vendor directories, from the app itself,
+// and from Godeps/_workspace when Workspace is set.
+type Checker struct {
+	AppDir     string
+	ImportPath string
+	Workspace  bool
+	Context    build.Context
+}
+
+// Check follows the imports of the packages to be installed, including
+// those of the app's own and vendored packages they pull in, and returns the
+// imports that nothing satisfies. Standard library imports, which have no
+// dot in their first path element, are not checked.
+func (c *Checker) Check(packages []string) ([]Repo, error) {
+	var queue []string
+	missing := map[string]map[string]bool{}
+	visited := map[string]bool{}
+
+	addMissing := func(path, importer string) {
+		if missing[path] == nil {
+			missing[path] = map[string]bool{}
+		}
+		missing[path][importer] = true
+	}
+
+	for _, pkg := range packages {
+		dirs, err := c.packageDirs(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if len(dirs) == 0 {
+			addMissing(strings.TrimSuffix(pkg, "/..."), "(packages to install)")
+		}
+		queue = append(queue, dirs...)
+	}
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if visited[dir] {
+			continue
+		}
+		visited[dir] = true
+
+		pkg, err := c.Context.ImportDir(dir, 0)
+		if err != nil && pkg == nil {
+			continue
+		}
+
+		for _, path := range pkg.Imports {
+			if path == "C" || (isStandard(path) && !c.inApp(path)) {
+				continue
+			}
+			if found, ok := c.resolve(path, dir); ok {
+				queue = append(queue, found)
+			} else {
+				addMissing(path, c.rel(dir))
+			}
+		}
+	}
+
+	repos := map[string]*Repo{}
+	for path, importers := range missing {
+		root := RootRepo(path)
+		if repos[root] == nil {
+			repos[root] = &Repo{Root: root}
+		}
+		imp := Import{Path: path}
+		for importer := range importers {
+			imp.ImportedBy = append(imp.ImportedBy, importer)
+		}
+		sort.Strings(imp.ImportedBy)
+		repos[root].Imports = append(repos[root].Imports, imp)
+	}
+
+	var result []Repo
+	for _, repo := range repos {
+		sort.Slice(repo.Imports, func(i, j int) bool { return repo.Imports[i].Path < repo.Imports[j].Path })
+		result = append(result, *repo)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Root < result[j].Root })
+	return result, nil
+}
+
+// RootRepo returns the repository an import path would be fetched from:
+// host/owner/repo on the common code hosts and host/repo elsewhere.
+func RootRepo(path string) string {
+	parts := strings.Split(path, "/")
+	n := 2
+	switch parts[0] {
+	case "github.com", "bitbucket.org", "gitlab.com", "golang.org":
+		n = 3
+	}
+	if len(parts) < n {
+		n = len(parts)
+	}
+	return strings.Join(parts[:n], "/")
+}
+
+// Shadowed finds packages vendored both in a vendor directory and in a
+// nested vendor directory below it.
+func Shadowed(appDir string) ([]Shadow, error) {
+	vendored := map[string]map[string]bool{}
+	var vendorDirs []string
+
+	err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == appDir {
+			return nil
+		}
+		if skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if info.Name() == "vendor" {
+			vendorDirs = append(vendorDirs, path)
+			vendored[path] = map[string]bool{}
+			return nil
+		}
+
+		vendorDir := enclosingVendorDir(appDir, path)
+		if vendorDir != "" && hasGoFiles(path) {
+			rel, err := filepath.Rel(vendorDir, path)
+			if err != nil {
+				return err
+			}
+			vendored[vendorDir][filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var shadows []Shadow
+	for _, outer := range vendorDirs {
+		for _, inner := range vendorDirs {
+			if inner == outer || !strings.HasPrefix(inner, filepath.Dir(outer)+string(filepath.Separator)) {
+				continue
+			}
+			for path := range vendored[inner] {
+				if vendored[outer][path] {
+					shadows = append(shadows, Shadow{Path: path, Outer: rel(appDir, outer), Inner: rel(appDir, inner)})
+				}
+			}
+		}
+	}
+
+	sort.Slice(shadows, func(i, j int) bool {
+		if shadows[i].Inner != shadows[j].Inner {
+			return shadows[i].Inner < shadows[j].Inner
+		}
+		return shadows[i].Path < shadows[j].Path
+	})
+	return shadows, nil
+}
+
+// packageDirs maps a package given to go install, which may be relative
+// ("./cmd/..."), an import path or a pattern, to directories.
+func (c *Checker) packageDirs(pkg string) ([]string, error) {
+	recursive := pkg == "..." || strings.HasSuffix(pkg, "/...")
+	pkg = strings.TrimSuffix(strings.TrimSuffix(pkg, "..."), "/")
+
+	var dir string
+	if pkg == "" || pkg == "." || strings.HasPrefix(pkg, "./") {
+		dir = filepath.Join(c.AppDir, pkg)
+	} else if found, ok := c.resolve(pkg, c.AppDir); ok {
+		dir = found
+	} else {
+		return nil, nil
+	}
+
+	if !recursive {
+		return []string{dir}, nil
+	}
+
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && (skipDir(info.Name()) || info.Name() == "vendor") {
+			return filepath.SkipDir
+		}
+		if hasGoFiles(path) {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// resolve finds the directory of an import from a package in fromDir,
+// looking in the vendor directories of fromDir and its parents within the
+// app first.
+func (c *Checker) resolve(path, fromDir string) (string, bool) {
+	for dir := fromDir; strings.HasPrefix(dir, c.AppDir); dir = filepath.Dir(dir) {
+		if candidate := filepath.Join(dir, "vendor", filepath.FromSlash(path)); isDir(candidate) {
+			return candidate, true
+		}
+		if dir == c.AppDir {
+			break
+		}
+	}
+
+	if c.inApp(path) {
+		candidate := filepath.Join(c.AppDir, filepath.FromSlash(strings.TrimPrefix(path, c.ImportPath)))
+		return candidate, isDir(candidate)
+	}
+
+	if c.Workspace {
+		if candidate := filepath.Join(c.AppDir, "Godeps", "_workspace", "src", filepath.FromSlash(path)); isDir(candidate) {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *Checker) inApp(path string) bool {
+	return path == c.ImportPath || strings.HasPrefix(path, c.ImportPath+"/")
+}
+
+func (c *Checker) rel(dir string) string {
+	return rel(c.AppDir, dir)
+}
+
+func rel(base, dir string) string {
+	r, err := filepath.Rel(base, dir)
+	if err != nil {
+		return dir
+	}
+	return filepath.ToSlash(r)
+}
+
+func enclosingVendorDir(appDir, dir string) string {
+	for d := filepath.Dir(dir); d != appDir && strings.HasPrefix(d, appDir); d = filepath.Dir(d) {
+		if filepath.Base(d) == "vendor" {
+			return d
+		}
+	}
+	return ""
+}
+
+func isStandard(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+func skipDir(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func hasGoFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	return len(matches) > 0
+}
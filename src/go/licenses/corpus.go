@@ -0,0 +1,62 @@
+package licenses
+
+// titleWindow is how far into a normalized text titles are looked for, which
+// is enough for a copyright line or two above the title.
+const titleWindow = 300
+
+// titles maps SPDX identifiers to the title at the top of the full license
+// text. Copyleft licenses quote each other's titles further down (GPL-3.0
+// and MPL-2.0 both name the Affero GPL), and permissive licenses may name
+// a GPL as an alternative, so a full text is identified by the first title
+// near its top rather than by any title it contains.
+var titles = []struct {
+	id    string
+	title string
+}{
+	{"AGPL-3.0", "gnu affero general public license version 3"},
+	{"LGPL-3.0", "gnu lesser general public license version 3"},
+	{"LGPL-2.1", "gnu lesser general public license version 2.1"},
+	{"LGPL-2.0", "gnu library general public license version 2"},
+	{"GPL-3.0", "gnu general public license version 3"},
+	{"GPL-2.0", "gnu general public license version 2"},
+	{"MPL-2.0", "mozilla public license version 2.0"},
+	{"EPL-1.0", "eclipse public license v 1.0"},
+	{"Apache-2.0", "apache license version 2.0"},
+}
+
+// corpus maps SPDX identifiers to phrases that only appear (together) in
+// that license's text, and is used for texts without a title, such as
+// license notices and the short permissive licenses. The entry whose first
+// phrase appears earliest wins, so an MIT text that later mentions the GPL
+// stays MIT. On a tie the earlier entry wins, so licenses whose text starts
+// like another one (e.g. BSD-3-Clause and BSD-2-Clause) must come first.
+// Phrases are in the normalized form produced by normalize.
+var corpus = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"LGPL-2.0", []string{"gnu library general public license", "version 2"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license version 2.0"}},
+	{"EPL-1.0", []string{"eclipse public license", "v 1.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted free of charge to any person obtaining a copy"}},
+	{"ISC", []string{"permission to use copy modify and/or distribute this software for any purpose with or without fee is hereby granted"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"creative commons", "cc0"}},
+	{"WTFPL", []string{"do what the fuck you want to public license"}},
+}
+
+// sourceExtensions are extensions of files that are named like license files
+// but hold code, such as license.go in a license checking package.
+var sourceExtensions = map[string]bool{
+	".go": true, ".s": true, ".c": true, ".h": true, ".cc": true, ".cpp": true,
+	".py": true, ".rb": true, ".js": true, ".ts": true, ".java": true, ".sh": true,
+	".proto": true, ".json": true, ".yml": true, ".yaml": true, ".toml": true, ".tmpl": true,
+}
@@ -0,0 +1,144 @@
+package licenses
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const Unknown = "UNKNOWN"
+
+type Package struct {
+	Path    string `json:"package"`
+	File    string `json:"file"`
+	License string `json:"license"`
+}
+
+// Scan walks each vendor root (vendor/, Godeps/_workspace/src, ...) for
+// license files and classifies them. Package paths are relative to the root
+// they were found in, so they read like import paths.
+func Scan(roots ...string) ([]Package, error) {
+	var packages []Package
+
+	for _, root := range roots {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !isLicenseFile(info.Name()) {
+				return nil
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			pkg, err := filepath.Rel(root, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+
+			packages = append(packages, Package{
+				Path:    filepath.ToSlash(pkg),
+				File:    info.Name(),
+				License: Classify(string(contents)),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool { return packages[i].Path < packages[j].Path })
+	return packages, nil
+}
+
+// Classify returns the SPDX identifier of the license text, or Unknown.
+func Classify(text string) string {
+	text = normalize(text)
+
+	head := text
+	if len(head) > titleWindow {
+		head = head[:titleWindow]
+	}
+
+	first, id := -1, Unknown
+	for _, license := range titles {
+		if i := strings.Index(head, license.title); i >= 0 && (first < 0 || i < first) {
+			first, id = i, license.id
+		}
+	}
+	if first >= 0 {
+		return id
+	}
+
+	for _, license := range corpus {
+		i := strings.Index(text, license.phrases[0])
+		if i < 0 || (first >= 0 && i >= first) {
+			continue
+		}
+		matched := true
+		for _, phrase := range license.phrases[1:] {
+			if !strings.Contains(text, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			first, id = i, license.id
+		}
+	}
+
+	return id
+}
+
+// Denied returns the packages whose license is on the deny list. A deny list
+// entry matches its exact identifier or the whole family, so "AGPL" denies
+// "AGPL-3.0".
+func Denied(packages []Package, denyList []string) []Package {
+	var denied []Package
+
+	for _, pkg := range packages {
+		for _, deny := range denyList {
+			if strings.EqualFold(pkg.License, deny) || strings.HasPrefix(strings.ToLower(pkg.License), strings.ToLower(deny)+"-") {
+				denied = append(denied, pkg)
+				break
+			}
+		}
+	}
+
+	return denied
+}
+
+func isLicenseFile(name string) bool {
+	name = strings.ToLower(name)
+	if sourceExtensions[filepath.Ext(name)] {
+		return false
+	}
+	for _, prefix := range []string{"license", "licence", "copying", "unlicense"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '/' {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,224 @@
+package lockfile
+
+import (
+	"bufio"
+	"go/godep"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+type Dependency struct {
+	Name     string
+	Version  string
+	Revision string
+}
+
+// Load returns the dependencies pinned by the app in appDir for the given
+// vendor tool, along with the app-relative path of the file they came from.
+// An empty source means the app does not record its dependencies.
+func Load(appDir, vendorTool string, gd godep.Godep) ([]Dependency, string, error) {
+	switch vendorTool {
+	case "godep":
+		var deps []Dependency
+		for _, d := range gd.Deps {
+			deps = append(deps, Dependency{Name: d.ImportPath, Version: d.Comment, Revision: d.Rev})
+		}
+		return deps, filepath.Join("Godeps", "Godeps.json"), nil
+
+	case "glide":
+		return loadIfExists(appDir, "glide.lock", loadGlideLock)
+
+	case "dep":
+		return loadIfExists(appDir, "Gopkg.lock", loadGopkgLock)
+
+	default:
+		for _, source := range []struct {
+			file string
+			load func(string) ([]Dependency, error)
+		}{
+			{filepath.Join("vendor", "modules.txt"), loadModulesTxt},
+			{"go.mod", loadGoMod},
+			{filepath.Join("vendor", "vendor.json"), loadVendorJSON},
+		} {
+			deps, file, err := loadIfExists(appDir, source.file, source.load)
+			if err != nil || file != "" {
+				return deps, file, err
+			}
+		}
+		return nil, "", nil
+	}
+}
+
+func loadIfExists(appDir, file string, load func(string) ([]Dependency, error)) ([]Dependency, string, error) {
+	exists, err := libbuildpack.FileExists(filepath.Join(appDir, file))
+	if err != nil || !exists {
+		return nil, "", err
+	}
+
+	deps, err := load(filepath.Join(appDir, file))
+	if err != nil {
+		return nil, "", err
+	}
+	return deps, file, nil
+}
+
+func loadGlideLock(file string) ([]Dependency, error) {
+	lock := struct {
+		Imports []struct {
+			Name    string `yaml:"name"`
+			Version string `yaml:"version"`
+		} `yaml:"imports"`
+	}{}
+	if err := libbuildpack.NewYAML().Load(file, &lock); err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, i := range lock.Imports {
+		deps = append(deps, Dependency{Name: i.Name, Revision: i.Version})
+	}
+	return deps, nil
+}
+
+func loadVendorJSON(file string) ([]Dependency, error) {
+	vendorJSON := struct {
+		Package []struct {
+			Path         string `json:"path"`
+			Revision     string `json:"revision"`
+			Version      string `json:"version"`
+			VersionExact string `json:"versionExact"`
+		} `json:"package"`
+	}{}
+	if err := libbuildpack.NewJSON().Load(file, &vendorJSON); err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, p := range vendorJSON.Package {
+		version := p.VersionExact
+		if version == "" {
+			version = p.Version
+		}
+		deps = append(deps, Dependency{Name: p.Path, Version: version, Revision: p.Revision})
+	}
+	return deps, nil
+}
+
+// loadGopkgLock understands just enough TOML to read the [[projects]]
+// tables that dep writes.
+func loadGopkgLock(file string) ([]Dependency, error) {
+	var deps []Dependency
+	var current *Dependency
+
+	err := eachLine(file, func(line string) {
+		if strings.HasPrefix(line, "#") {
+			return
+		}
+		if strings.HasPrefix(line, "[") {
+			if current != nil {
+				deps = append(deps, *current)
+				current = nil
+			}
+			if line == "[[projects]]" {
+				current = &Dependency{}
+			}
+			return
+		}
+		if current == nil {
+			return
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return
+		}
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		switch strings.TrimSpace(parts[0]) {
+		case "name":
+			current.Name = value
+		case "version":
+			current.Version = value
+		case "revision":
+			current.Revision = value
+		}
+	})
+	if current != nil {
+		deps = append(deps, *current)
+	}
+	return deps, err
+}
+
+func loadGoMod(file string) ([]Dependency, error) {
+	var deps []Dependency
+	inRequire := false
+
+	err := eachLine(file, func(line string) {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case inRequire && line == ")":
+			inRequire = false
+			return
+		case line == "require (":
+			inRequire = true
+			return
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			deps = append(deps, Dependency{Name: fields[0], Version: fields[1]})
+		}
+	})
+	return deps, err
+}
+
+// loadModulesTxt reads the "# module version" lines that 'go mod vendor'
+// writes. Replaced modules are listed as their replacement, unless that is
+// a local directory.
+func loadModulesTxt(file string) ([]Dependency, error) {
+	var deps []Dependency
+
+	err := eachLine(file, func(line string) {
+		if !strings.HasPrefix(line, "# ") {
+			return
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "# "), "=>", 2)
+		fields := strings.Fields(parts[0])
+		if len(parts) == 2 {
+			if replacement := strings.Fields(parts[1]); len(replacement) == 2 {
+				fields = replacement
+			}
+		}
+
+		if len(fields) == 2 {
+			deps = append(deps, Dependency{Name: fields[0], Version: fields[1]})
+		}
+	})
+	return deps, err
+}
+
+func eachLine(file string, fn func(string)) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,87 @@
+package platform
+
+import (
+	"debug/elf"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// uriArchs maps the architecture names used in dependency tarball names to
+// GOARCH values.
+var uriArchs = []struct {
+	marker string
+	arch   string
+}{
+	{"linux-amd64", "amd64"},
+	{"linux-x64", "amd64"},
+	{"linux-x86_64", "amd64"},
+	{"linux-arm64", "arm64"},
+	{"linux-aarch64", "arm64"},
+}
+
+var machines = map[string]elf.Machine{
+	"386":     elf.EM_386,
+	"amd64":   elf.EM_X86_64,
+	"arm":     elf.EM_ARM,
+	"arm64":   elf.EM_AARCH64,
+	"ppc64le": elf.EM_PPC64,
+	"s390x":   elf.EM_S390,
+}
+
+// HostArch is the GOARCH of the cell staging the app.
+func HostArch() string {
+	return runtime.GOARCH
+}
+
+// URIArch returns the GOARCH a dependency was built for, going by its
+// tarball name, or "" if the name doesn't say.
+func URIArch(uri string) string {
+	for _, u := range uriArchs {
+		if strings.Contains(uri, u.marker) {
+			return u.arch
+		}
+	}
+	return ""
+}
+
+// FilterEntries drops manifest entries built for another architecture.
+// Entries that don't name an architecture are assumed to be amd64, which is
+// all the buildpack shipped before arm64 stacks.
+func FilterEntries(entries []libbuildpack.ManifestEntry, arch string) []libbuildpack.ManifestEntry {
+	var filtered []libbuildpack.ManifestEntry
+	for _, e := range entries {
+		entryArch := URIArch(e.URI)
+		if entryArch == "" {
+			entryArch = "amd64"
+		}
+		if entryArch == arch {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// CheckBinary returns an error if file is an ELF binary for a machine other
+// than goarch. Files that aren't ELF binaries, such as scripts, pass.
+func CheckBinary(file, goarch string) error {
+	f, err := elf.Open(file)
+	if err != nil {
+		if _, ok := err.(*elf.FormatError); ok {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	want, ok := machines[goarch]
+	if !ok {
+		return fmt.Errorf("unsupported GOARCH %s", goarch)
+	}
+	if f.Machine != want {
+		return fmt.Errorf("built for %s, expected %s for GOARCH %s", f.Machine, want, goarch)
+	}
+	return nil
+}
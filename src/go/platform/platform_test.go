@@ -0,0 +1,42 @@
+package platform_test
+
+import (
+	"go/platform"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Platform", func() {
+	Describe("FilterEntries", func() {
+		var entries []libbuildpack.ManifestEntry
+
+		entry := func(name, uri string) libbuildpack.ManifestEntry {
+			return libbuildpack.ManifestEntry{Dependency: libbuildpack.Dependency{Name: name}, URI: uri}
+		}
+
+		BeforeEach(func() {
+			entries = []libbuildpack.ManifestEntry{
+				entry("go", "https://example.com/go/go1.9.1.linux-amd64-0571886e.tar.gz"),
+				entry("go", "https://example.com/go/go1.9.1.linux-arm64-1a2b3c4d.tar.gz"),
+				entry("dep", "https://example.com/dep/dep-v0.3.2-linux-x64-8910d5c1.tgz"),
+				entry("glide", "https://example.com/glide/glide-v0.13.1-linux-aarch64-4959fbf0.tgz"),
+				entry("hello", "https://example.com/hello.tgz"),
+			}
+		})
+
+		It("keeps amd64 entries, including those that name no architecture", func() {
+			Expect(platform.FilterEntries(entries, "amd64")).To(Equal([]libbuildpack.ManifestEntry{entries[0], entries[2], entries[4]}))
+		})
+
+		It("keeps arm64 entries, whichever name the tarball uses", func() {
+			Expect(platform.FilterEntries(entries, "arm64")).To(Equal([]libbuildpack.ManifestEntry{entries[1], entries[3]}))
+		})
+
+		It("keeps nothing for an architecture the manifest lacks", func() {
+			Expect(platform.FilterEntries(entries, "s390x")).To(BeEmpty())
+		})
+	})
+})
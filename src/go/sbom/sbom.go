@@ -0,0 +1,106 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"go/lockfile"
+	"time"
+)
+
+// Document is the subset of a CycloneDX 1.4 JSON bill of materials that the
+// buildpack fills in.
+type Document struct {
+	BOMFormat    string      `json:"bomFormat"`
+	SpecVersion  string      `json:"specVersion"`
+	SerialNumber string      `json:"serialNumber"`
+	Version      int         `json:"version"`
+	Metadata     Metadata    `json:"metadata"`
+	Components   []Component `json:"components"`
+}
+
+type Metadata struct {
+	Timestamp  string     `json:"timestamp"`
+	Tools      []Tool     `json:"tools"`
+	Component  Component  `json:"component"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+type Tool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type Component struct {
+	Type       string     `json:"type"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// New describes an app built with the given Go toolchain and vendor tool.
+// The toolchain is listed as the first component, followed by deps.
+func New(appName, buildpackVersion, goVersion, vendorTool string, deps []lockfile.Dependency) Document {
+	doc := Document{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []Tool{{Vendor: "Cloud Foundry", Name: "go-buildpack", Version: buildpackVersion}},
+			Component: Component{Type: "application", Name: appName},
+			Properties: []Property{
+				{Name: "cloudfoundry:go:vendor-tool", Value: vendorTool},
+			},
+		},
+		Components: []Component{{
+			Type:    "framework",
+			Name:    "go",
+			Version: goVersion,
+			PURL:    fmt.Sprintf("pkg:golang/stdlib@go%s", goVersion),
+		}},
+	}
+
+	for _, dep := range deps {
+		doc.Components = append(doc.Components, dependencyComponent(dep))
+	}
+
+	return doc
+}
+
+func dependencyComponent(dep lockfile.Dependency) Component {
+	component := Component{Type: "library", Name: dep.Name, Version: dep.Version}
+	if component.Version == "" {
+		component.Version = dep.Revision
+	}
+
+	if component.Version != "" {
+		component.PURL = fmt.Sprintf("pkg:golang/%s@%s", dep.Name, component.Version)
+	} else {
+		component.PURL = fmt.Sprintf("pkg:golang/%s", dep.Name)
+	}
+
+	if dep.Revision != "" && dep.Revision != component.Version {
+		component.Properties = []Property{{Name: "cloudfoundry:go:revision", Value: dep.Revision}}
+	}
+
+	return component
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
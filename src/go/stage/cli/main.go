@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"go/stage"
+	"os"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+type envFlags []string
+
+func (e *envFlags) String() string {
+	return ""
+}
+
+func (e *envFlags) Set(value string) error {
+	*e = append(*e, value)
+	return nil
+}
+
+func main() {
+	logger := libbuildpack.NewLogger(os.Stdout)
+
+	var env envFlags
+	s := stage.Stage{Log: logger}
+
+	flag.StringVar(&s.BuildpackDir, "buildpack", "", "buildpack directory (defaults to the one this command was built from)")
+	flag.StringVar(&s.AppDir, "app", ".", "app directory to stage")
+	flag.StringVar(&s.CacheDir, "cache", "", "cache directory to reuse between runs (defaults to a fresh one)")
+	flag.StringVar(&s.OutputDir, "output", ".", "directory to write droplet.tgz and release.yml to")
+	flag.Var(&env, "env", "KEY=VALUE to set for staging, may be repeated")
+	stack := flag.String("stack", "cflinuxfs2", "stack to stage for, unless $CF_STACK is set")
+	flag.Parse()
+
+	if s.BuildpackDir == "" {
+		buildpackDir, err := libbuildpack.GetBuildpackDir()
+		if err != nil {
+			logger.Error("Unable to determine buildpack directory: %s", err.Error())
+			os.Exit(8)
+		}
+		s.BuildpackDir = buildpackDir
+	}
+
+	if os.Getenv("CF_STACK") == "" {
+		env = append(envFlags{"CF_STACK=" + *stack}, env...)
+	}
+	s.Env = env
+
+	if err := stage.Run(&s); err != nil {
+		os.Exit(12)
+	}
+}
@@ -0,0 +1,230 @@
+package stage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// Stage runs the buildpack's bin/ scripts against a copy of an app, the way
+// Cloud Foundry would, and packs the result into a droplet.
+type Stage struct {
+	BuildpackDir string
+	AppDir       string
+	CacheDir     string
+	OutputDir    string
+	Env          []string
+	Log          *libbuildpack.Logger
+
+	BuildDir      string
+	DepsDir       string
+	BuildpackName string
+	ReleaseYAML   string
+	StartCommand  string
+	workDir       string
+}
+
+func Run(s *Stage) error {
+	if err := s.CreateStagingDirs(); err != nil {
+		s.Log.Error("Unable to create staging directories: %s", err.Error())
+		return err
+	}
+	defer os.RemoveAll(s.workDir)
+
+	if err := s.Detect(); err != nil {
+		s.Log.Error("Buildpack did not detect the app: %s", err.Error())
+		return err
+	}
+
+	if err := s.runScript("supply", s.BuildDir, s.CacheDir, s.DepsDir, "0"); err != nil {
+		s.Log.Error("Supply failed: %s", err.Error())
+		return err
+	}
+
+	if err := s.runScript("finalize", s.BuildDir, s.CacheDir, s.DepsDir, "0", filepath.Join(s.BuildDir, ".profile.d")); err != nil {
+		s.Log.Error("Finalize failed: %s", err.Error())
+		return err
+	}
+
+	if err := s.Release(); err != nil {
+		s.Log.Error("Release failed: %s", err.Error())
+		return err
+	}
+
+	if err := s.WriteDroplet(); err != nil {
+		s.Log.Error("Unable to write droplet: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// CreateStagingDirs lays out BUILD_DIR, CACHE_DIR and DEPS_DIR under a
+// temporary directory, with BUILD_DIR a copy of the app. A CacheDir that
+// is already set is kept, so it can be reused between runs.
+func (s *Stage) CreateStagingDirs() error {
+	workDir, err := ioutil.TempDir("", "gobuildpack.stage")
+	if err != nil {
+		return err
+	}
+	s.workDir = workDir
+
+	s.BuildDir = filepath.Join(workDir, "app")
+	s.DepsDir = filepath.Join(workDir, "deps")
+	if s.CacheDir == "" {
+		s.CacheDir = filepath.Join(workDir, "cache")
+	}
+
+	for _, dir := range []string{s.BuildDir, s.CacheDir, filepath.Join(s.DepsDir, "0"), s.OutputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	return libbuildpack.CopyDirectory(s.AppDir, s.BuildDir)
+}
+
+func (s *Stage) Detect() error {
+	s.Log.BeginStep("Running detect")
+
+	output := new(bytes.Buffer)
+	if err := s.command("detect", output, s.BuildDir).Run(); err != nil {
+		return err
+	}
+
+	s.BuildpackName = strings.TrimSpace(output.String())
+	s.Log.Info("Detected %s", s.BuildpackName)
+	return nil
+}
+
+func (s *Stage) Release() error {
+	s.Log.BeginStep("Running release")
+
+	output := new(bytes.Buffer)
+	if err := s.command("release", output, s.BuildDir).Run(); err != nil {
+		return err
+	}
+	s.ReleaseYAML = output.String()
+
+	release := struct {
+		DefaultProcessTypes struct {
+			Web string `yaml:"web"`
+		} `yaml:"default_process_types"`
+	}{}
+	releaseFile := filepath.Join(s.OutputDir, "release.yml")
+	if err := ioutil.WriteFile(releaseFile, output.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := libbuildpack.NewYAML().Load(releaseFile, &release); err != nil {
+		return err
+	}
+	s.StartCommand = release.DefaultProcessTypes.Web
+
+	s.Log.Info("Wrote %s", releaseFile)
+	return nil
+}
+
+// WriteDroplet packs the staged app and deps the way they are laid out in
+// a Cloud Foundry container (/home/vcap/app and /home/vcap/deps), along with
+// the staging_info.yml the platform records.
+func (s *Stage) WriteDroplet() error {
+	dropletFile := filepath.Join(s.OutputDir, "droplet.tgz")
+	s.Log.BeginStep("Writing droplet to %s", dropletFile)
+
+	fh, err := os.Create(dropletFile)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	gz := gzip.NewWriter(fh)
+	tw := tar.NewWriter(gz)
+
+	stagingInfo := fmt.Sprintf("detected_buildpack: %q\nstart_command: %q\n", s.BuildpackName, s.StartCommand)
+	if err := tw.WriteHeader(&tar.Header{Name: "staging_info.yml", Mode: 0644, Size: int64(len(stagingInfo))}); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(stagingInfo)); err != nil {
+		return err
+	}
+
+	if err := addDir(tw, s.BuildDir, "app"); err != nil {
+		return err
+	}
+	if err := addDir(tw, s.DepsDir, "deps"); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (s *Stage) runScript(script string, args ...string) error {
+	s.Log.BeginStep("Running %s", script)
+	return s.command(script, s.Log.Output(), args...).Run()
+}
+
+func (s *Stage) command(script string, stdout io.Writer, args ...string) *exec.Cmd {
+	cmd := exec.Command(filepath.Join(s.BuildpackDir, "bin", script), args...)
+	cmd.Dir = s.BuildDir
+	cmd.Env = append(os.Environ(), s.Env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = s.Log.Output()
+	return cmd
+}
+
+func addDir(tw *tar.Writer, dir, prefix string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		fh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		_, err = io.Copy(tw, fh)
+		return err
+	})
+}
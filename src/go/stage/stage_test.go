@@ -0,0 +1,161 @@
+package stage_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go/stage"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stage", func() {
+	var (
+		buildpackDir string
+		appDir       string
+		outputDir    string
+		s            *stage.Stage
+		buffer       *bytes.Buffer
+		err          error
+	)
+
+	writeScript := func(name, contents string) {
+		err = ioutil.WriteFile(filepath.Join(buildpackDir, "bin", name), []byte("#!/usr/bin/env bash\nset -e\n"+contents), 0755)
+		Expect(err).To(BeNil())
+	}
+
+	dropletFiles := func() map[string]string {
+		fh, err := os.Open(filepath.Join(outputDir, "droplet.tgz"))
+		Expect(err).To(BeNil())
+		defer fh.Close()
+
+		gz, err := gzip.NewReader(fh)
+		Expect(err).To(BeNil())
+
+		files := map[string]string{}
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).To(BeNil())
+
+			contents, err := ioutil.ReadAll(tr)
+			Expect(err).To(BeNil())
+			files[header.Name] = string(contents)
+		}
+		return files
+	}
+
+	BeforeEach(func() {
+		buildpackDir, err = ioutil.TempDir("", "go-buildpack.buildpack.")
+		Expect(err).To(BeNil())
+		err = os.MkdirAll(filepath.Join(buildpackDir, "bin"), 0755)
+		Expect(err).To(BeNil())
+
+		appDir, err = ioutil.TempDir("", "go-buildpack.app.")
+		Expect(err).To(BeNil())
+		err = ioutil.WriteFile(filepath.Join(appDir, "main.go"), []byte("package main"), 0644)
+		Expect(err).To(BeNil())
+
+		outputDir, err = ioutil.TempDir("", "go-buildpack.output.")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+
+		writeScript("detect", "echo Go\n")
+		writeScript("supply", `echo "supplied for $CF_STACK" > "$3/$4/supplied"`+"\n")
+		writeScript("finalize", `mkdir -p "$1/bin" && echo binary > "$1/bin/app"`+"\n")
+		writeScript("release", "echo '---'\necho 'default_process_types:'\necho '    web: app'\n")
+	})
+
+	JustBeforeEach(func() {
+		s = &stage.Stage{
+			BuildpackDir: buildpackDir,
+			AppDir:       appDir,
+			OutputDir:    outputDir,
+			Env:          []string{"CF_STACK=cflinuxfs2"},
+			Log:          libbuildpack.NewLogger(ansicleaner.New(buffer)),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(buildpackDir)).To(Succeed())
+		Expect(os.RemoveAll(appDir)).To(Succeed())
+		Expect(os.RemoveAll(outputDir)).To(Succeed())
+	})
+
+	Describe("Run", func() {
+		It("writes the staged app and deps to a droplet", func() {
+			err = stage.Run(s)
+			Expect(err).To(BeNil())
+
+			files := dropletFiles()
+			Expect(files).To(HaveKeyWithValue("app/main.go", "package main"))
+			Expect(files).To(HaveKeyWithValue("app/bin/app", "binary\n"))
+			Expect(files).To(HaveKeyWithValue("deps/0/supplied", "supplied for cflinuxfs2\n"))
+			Expect(files).To(HaveKeyWithValue("staging_info.yml", "detected_buildpack: \"Go\"\nstart_command: \"app\"\n"))
+		})
+
+		It("writes the release metadata", func() {
+			err = stage.Run(s)
+			Expect(err).To(BeNil())
+
+			contents, err := ioutil.ReadFile(filepath.Join(outputDir, "release.yml"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(Equal("---\ndefault_process_types:\n    web: app\n"))
+		})
+
+		It("does not modify the app directory", func() {
+			err = stage.Run(s)
+			Expect(err).To(BeNil())
+
+			Expect(filepath.Join(appDir, "bin")).NotTo(BeADirectory())
+		})
+
+		It("removes the temporary staging directories", func() {
+			err = stage.Run(s)
+			Expect(err).To(BeNil())
+
+			Expect(s.BuildDir).NotTo(BeADirectory())
+		})
+
+		Context("the buildpack does not detect the app", func() {
+			BeforeEach(func() {
+				writeScript("detect", "exit 1\n")
+			})
+
+			It("logs an error and does not write a droplet", func() {
+				err = stage.Run(s)
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("**ERROR** Buildpack did not detect the app"))
+				Expect(filepath.Join(outputDir, "droplet.tgz")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("finalize fails", func() {
+			BeforeEach(func() {
+				writeScript("finalize", "echo 'compile error'\nexit 12\n")
+			})
+
+			It("shows the script output and returns an error", func() {
+				err = stage.Run(s)
+				Expect(err).NotTo(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("compile error"))
+				Expect(buffer.String()).To(ContainSubstring("**ERROR** Finalize failed: exit status 12"))
+			})
+		})
+	})
+})
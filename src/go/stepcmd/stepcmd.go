@@ -0,0 +1,89 @@
+package stepcmd
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeouts bound the network-bound vendor steps, which otherwise hang
+// until the platform kills staging when a remote stops responding.
+var DefaultTimeouts = map[string]time.Duration{
+	"dep":   15 * time.Minute,
+	"glide": 15 * time.Minute,
+}
+
+// TimeoutError is returned when a command runs longer than its timeout.
+type TimeoutError struct {
+	Program string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Program, e.Timeout)
+}
+
+// Command runs programs the way libbuildpack.Command does, but each in its
+// own process group. If a program has a timeout and runs past it, the whole
+// group is killed, so children such as git and hg do not keep it alive.
+type Command struct {
+	Timeouts map[string]time.Duration
+}
+
+func (c *Command) Execute(dir string, stdout io.Writer, stderr io.Writer, program string, args ...string) error {
+	cmd := exec.Command(program, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	timeout := c.Timeouts[program]
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return &TimeoutError{Program: program, Timeout: timeout}
+	}
+}
+
+// ParseTimeouts reads timeouts such as "dep=10m,go=30m" into a copy of
+// DefaultTimeouts. A timeout of 0 removes the limit for that program.
+func ParseTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for program, timeout := range DefaultTimeouts {
+		timeouts[program] = timeout
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("expected program=duration, got %q", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout for %s: %q", strings.TrimSpace(parts[0]), parts[1])
+		}
+		timeouts[strings.TrimSpace(parts[0])] = timeout
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,13 @@
+package stepcmd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestStepcmd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Stepcmd Suite")
+}
@@ -0,0 +1,84 @@
+package stepcmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"go/stepcmd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stepcmd", func() {
+	var (
+		dir     string
+		command *stepcmd.Command
+		err     error
+	)
+
+	BeforeEach(func() {
+		dir, err = ioutil.TempDir("", "go-buildpack.stepcmd.")
+		Expect(err).To(BeNil())
+
+		command = &stepcmd.Command{Timeouts: map[string]time.Duration{"sh": 200 * time.Millisecond}}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("Execute", func() {
+		It("runs commands that finish within their timeout", func() {
+			output := new(bytes.Buffer)
+			err = command.Execute(dir, output, output, "sh", "-c", "echo done")
+			Expect(err).To(BeNil())
+			Expect(output.String()).To(Equal("done\n"))
+		})
+
+		It("kills the whole process group when the timeout expires", func() {
+			output := new(bytes.Buffer)
+			start := time.Now()
+
+			err = command.Execute(dir, output, output, "sh", "-c", "sleep 30; echo done")
+			Expect(err).To(MatchError("sh timed out after 200ms"))
+			Expect(err).To(BeAssignableToTypeOf(&stepcmd.TimeoutError{}))
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			Expect(output.String()).To(BeEmpty())
+		})
+
+		It("does not time out programs without a timeout", func() {
+			err = command.Execute(dir, ioutil.Discard, ioutil.Discard, "sleep", "0.3")
+			Expect(err).To(BeNil())
+		})
+
+		It("returns the exit status of failed commands", func() {
+			err = command.Execute(dir, ioutil.Discard, ioutil.Discard, "sh", "-c", "exit 3")
+			Expect(err).To(MatchError("exit status 3"))
+		})
+	})
+
+	Describe("ParseTimeouts", func() {
+		It("overrides and adds to the default timeouts", func() {
+			timeouts, err := stepcmd.ParseTimeouts("dep=5m, go=30m,glide=0")
+			Expect(err).To(BeNil())
+			Expect(timeouts).To(Equal(map[string]time.Duration{"dep": 5 * time.Minute, "glide": 0, "go": 30 * time.Minute}))
+		})
+
+		It("uses the defaults when nothing is set", func() {
+			timeouts, err := stepcmd.ParseTimeouts("")
+			Expect(err).To(BeNil())
+			Expect(timeouts).To(Equal(stepcmd.DefaultTimeouts))
+		})
+
+		It("rejects malformed entries", func() {
+			_, err := stepcmd.ParseTimeouts("dep")
+			Expect(err).To(MatchError(`expected program=duration, got "dep"`))
+
+			_, err = stepcmd.ParseTimeouts("dep=soon")
+			Expect(err).To(MatchError(`invalid timeout for dep: "soon"`))
+		})
+	})
+})
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"go/eventlog"
 	_ "go/hooks"
+	"go/platform"
 	"go/supply"
 	"os"
 	"time"
@@ -9,43 +11,102 @@ import (
 	"github.com/cloudfoundry/libbuildpack"
 )
 
+// Exit codes for supply, and the reasons JSON output reports for them. The
+// README lists both phases' codes.
+const (
+	exitBuildpackDir     = 8
+	exitManifest         = 9
+	exitInvalidBuildpack = 10
+	exitBeforeCompile    = 12
+	exitStagingEnv       = 13
+	exitSupply           = 16
+	exitMirror           = 17
+)
+
+var failureReasons = map[int]string{
+	exitBuildpackDir:     "buildpack_dir",
+	exitManifest:         "manifest",
+	exitInvalidBuildpack: "invalid_buildpack",
+	exitBeforeCompile:    "before_compile_hook",
+	exitStagingEnv:       "staging_environment",
+	exitSupply:           eventlog.FromStep,
+	exitMirror:           "dependency_mirror",
+}
+
 func main() {
+	var events *eventlog.Writer
 	logger := libbuildpack.NewLogger(os.Stdout)
+	if eventlog.Enabled() {
+		events = eventlog.NewWriter(os.Stdout, "supply", failureReasons)
+		logger = libbuildpack.NewLogger(events)
+	}
+
+	exit := func(code int) {
+		events.Finish(code)
+		os.Exit(code)
+	}
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
 		logger.Error("Unable to determine buildpack directory: %s", err.Error())
-		os.Exit(8)
+		exit(exitBuildpackDir)
 	}
 
 	manifest, err := libbuildpack.NewManifest(buildpackDir, logger, time.Now())
 	if err != nil {
 		logger.Error("Unable to load buildpack manifest: %s", err.Error())
-		os.Exit(9)
+		exit(exitManifest)
 	}
 
+	manifest.ManifestEntries = platform.FilterEntries(manifest.ManifestEntries, platform.HostArch())
+
 	stager := libbuildpack.NewStager(os.Args[1:], logger, manifest)
 	if err = stager.CheckBuildpackValid(); err != nil {
-		os.Exit(10)
+		exit(exitInvalidBuildpack)
 	}
 
 	if err := libbuildpack.RunBeforeCompile(stager); err != nil {
 		logger.Error("Before Compile: %s", err.Error())
-		os.Exit(12)
+		exit(exitBeforeCompile)
 	}
 
 	if err := stager.SetStagingEnvironment(); err != nil {
 		logger.Error("Unable to setup environment variables: %s", err.Error())
-		os.Exit(13)
+		exit(exitStagingEnv)
+	}
+
+	stack := os.Getenv("CF_STACK")
+	stackManifest := &supply.StackManifest{Manifest: manifest, Stack: stack, AllEntries: manifest.ManifestEntries}
+	manifest.ManifestEntries = supply.StackEntries(manifest.ManifestEntries, stack)
+
+	mirror, err := supply.DependencyMirror(buildpackDir)
+	if err != nil {
+		logger.Error("Unable to read dependency mirror: %s", err.Error())
+		exit(exitMirror)
+	}
+	if mirror != "" {
+		if manifest.IsCached() {
+			logger.Info("Ignoring dependency mirror, the buildpack is packaged with its dependencies")
+		} else if err := stackManifest.UseMirror(mirror); err != nil {
+			logger.Error("Invalid dependency mirror: %s", err.Error())
+			exit(exitMirror)
+		} else {
+			logger.Info("Using dependency mirror %s", stackManifest.Mirror)
+		}
 	}
 
 	gs := supply.Supplier{
-		Stager:   stager,
-		Log:      logger,
-		Manifest: manifest,
+		Stager:       stager,
+		Log:          logger,
+		BuildpackDir: buildpackDir,
+		Command:      &libbuildpack.Command{},
+		Manifest:     stackManifest,
+		PlanOnly:     os.Getenv("GO_STAGING_PLAN") == "true",
 	}
 
 	if err := supply.Run(&gs); err != nil {
-		os.Exit(16)
+		exit(exitSupply)
 	}
+
+	events.Finish(0)
 }
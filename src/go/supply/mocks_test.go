@@ -7,9 +7,50 @@ package supply_test
 import (
 	libbuildpack "github.com/cloudfoundry/libbuildpack"
 	gomock "github.com/golang/mock/gomock"
+	io "io"
 	reflect "reflect"
 )
 
+// MockCommand is a mock of Command interface
+type MockCommand struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommandMockRecorder
+}
+
+// MockCommandMockRecorder is the mock recorder for MockCommand
+type MockCommandMockRecorder struct {
+	mock *MockCommand
+}
+
+// NewMockCommand creates a new mock instance
+func NewMockCommand(ctrl *gomock.Controller) *MockCommand {
+	mock := &MockCommand{ctrl: ctrl}
+	mock.recorder = &MockCommandMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockCommand) EXPECT() *MockCommandMockRecorder {
+	return m.recorder
+}
+
+// Execute mocks base method
+func (m *MockCommand) Execute(arg0 string, arg1, arg2 io.Writer, arg3 string, arg4 ...string) error {
+	varargs := []interface{}{arg0, arg1, arg2, arg3}
+	for _, a := range arg4 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Execute", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Execute indicates an expected call of Execute
+func (mr *MockCommandMockRecorder) Execute(arg0, arg1, arg2, arg3 interface{}, arg4 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3}, arg4...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockCommand)(nil).Execute), varargs...)
+}
+
 // MockManifest is a mock of Manifest interface
 type MockManifest struct {
 	ctrl     *gomock.Controller
@@ -45,6 +86,18 @@ func (mr *MockManifestMockRecorder) AllDependencyVersions(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllDependencyVersions", reflect.TypeOf((*MockManifest)(nil).AllDependencyVersions), arg0)
 }
 
+// DependencyStacks mocks base method
+func (m *MockManifest) DependencyStacks(arg0 string) map[string][]string {
+	ret := m.ctrl.Call(m, "DependencyStacks", arg0)
+	ret0, _ := ret[0].(map[string][]string)
+	return ret0
+}
+
+// DependencyStacks indicates an expected call of DependencyStacks
+func (mr *MockManifestMockRecorder) DependencyStacks(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DependencyStacks", reflect.TypeOf((*MockManifest)(nil).DependencyStacks), arg0)
+}
+
 // DefaultVersion mocks base method
 func (m *MockManifest) DefaultVersion(arg0 string) (libbuildpack.Dependency, error) {
 	ret := m.ctrl.Call(m, "DefaultVersion", arg0)
@@ -58,6 +111,30 @@ func (mr *MockManifestMockRecorder) DefaultVersion(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefaultVersion", reflect.TypeOf((*MockManifest)(nil).DefaultVersion), arg0)
 }
 
+// DependencyDeprecations mocks base method
+func (m *MockManifest) DependencyDeprecations(arg0 string) []libbuildpack.DeprecationDate {
+	ret := m.ctrl.Call(m, "DependencyDeprecations", arg0)
+	ret0, _ := ret[0].([]libbuildpack.DeprecationDate)
+	return ret0
+}
+
+// DependencyDeprecations indicates an expected call of DependencyDeprecations
+func (mr *MockManifestMockRecorder) DependencyDeprecations(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DependencyDeprecations", reflect.TypeOf((*MockManifest)(nil).DependencyDeprecations), arg0)
+}
+
+// FetchDependency mocks base method
+func (m *MockManifest) FetchDependency(arg0 libbuildpack.Dependency, arg1 string) error {
+	ret := m.ctrl.Call(m, "FetchDependency", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchDependency indicates an expected call of FetchDependency
+func (mr *MockManifestMockRecorder) FetchDependency(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchDependency", reflect.TypeOf((*MockManifest)(nil).FetchDependency), arg0, arg1)
+}
+
 // InstallDependency mocks base method
 func (m *MockManifest) InstallDependency(arg0 libbuildpack.Dependency, arg1 string) error {
 	ret := m.ctrl.Call(m, "InstallDependency", arg0, arg1)
@@ -1,23 +1,41 @@
 package supply
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go/data"
 	"go/godep"
+	"go/importpath"
+	"go/platform"
 	"go/warnings"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/cloudfoundry/libbuildpack"
 )
 
+type Command interface {
+	Execute(string, io.Writer, io.Writer, string, ...string) error
+}
+
 type Manifest interface {
 	AllDependencyVersions(string) []string
+	DependencyStacks(string) map[string][]string
 	DefaultVersion(string) (libbuildpack.Dependency, error)
+	DependencyDeprecations(string) []libbuildpack.DeprecationDate
+	FetchDependency(libbuildpack.Dependency, string) error
 	InstallDependency(libbuildpack.Dependency, string) error
 	InstallOnlyVersion(string, string) error
 }
@@ -32,13 +50,194 @@ type Stager interface {
 	WriteProfileD(string, string) error
 }
 
+// StackManifest limits a buildpack manifest's dependency versions to those
+// published for one stack, optionally downloading them from a mirror. The
+// embedded manifest's entries should already be filtered by StackEntries,
+// so that downloads pick the stack's build of a version. AllEntries keeps
+// the unfiltered list to say which stacks have a missing version.
+type StackManifest struct {
+	*libbuildpack.Manifest
+	Stack      string
+	Mirror     string
+	AllEntries []libbuildpack.ManifestEntry
+}
+
+// StackEntries drops manifest entries that are not published for stack.
+func StackEntries(entries []libbuildpack.ManifestEntry, stack string) []libbuildpack.ManifestEntry {
+	var filtered []libbuildpack.ManifestEntry
+	for _, e := range entries {
+		for _, s := range e.CFStacks {
+			if s == stack {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func (m *StackManifest) DependencyDeprecations(depName string) []libbuildpack.DeprecationDate {
+	var deprecations []libbuildpack.DeprecationDate
+	for _, d := range m.Deprecations {
+		if d.Name == depName {
+			deprecations = append(deprecations, d)
+		}
+	}
+	return deprecations
+}
+
+var registerFileTransport sync.Once
+
+// DependencyMirror returns the operator's dependency mirror: $BP_DEPENDENCY_MIRROR,
+// or else the first line of the dependency-mirror file in the buildpack.
+func DependencyMirror(buildpackDir string) (string, error) {
+	if mirror := os.Getenv("BP_DEPENDENCY_MIRROR"); mirror != "" {
+		return mirror, nil
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(buildpackDir, "dependency-mirror"))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", nil
+}
+
+// UseMirror rewrites every dependency URI to the same path under mirror, an
+// http(s):// or file:// URL. The sha256 of each dependency is unchanged, so
+// downloads from the mirror are verified like any other.
+func (m *StackManifest) UseMirror(mirror string) error {
+	base, err := url.Parse(mirror)
+	if err != nil {
+		return err
+	}
+	switch base.Scheme {
+	case "http", "https":
+	case "file":
+		registerFileTransport.Do(func() {
+			http.DefaultTransport.(*http.Transport).RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+		})
+	default:
+		return fmt.Errorf("dependency mirror %s must be an http://, https:// or file:// URL", redactURL(base))
+	}
+
+	for i, entry := range m.ManifestEntries {
+		uri, err := url.Parse(entry.URI)
+		if err != nil {
+			return err
+		}
+		m.ManifestEntries[i].URI = strings.TrimSuffix(mirror, "/") + uri.Path
+	}
+
+	m.Mirror = redactURL(base)
+	return nil
+}
+
+func (m *StackManifest) FetchDependency(dep libbuildpack.Dependency, outputFile string) error {
+	return m.mirrorError(m.Manifest.FetchDependency(dep, outputFile))
+}
+
+func (m *StackManifest) InstallDependency(dep libbuildpack.Dependency, outputDir string) error {
+	return m.mirrorError(m.Manifest.InstallDependency(dep, outputDir))
+}
+
+func (m *StackManifest) InstallOnlyVersion(depName string, installDir string) error {
+	return m.mirrorError(m.Manifest.InstallOnlyVersion(depName, installDir))
+}
+
+func (m *StackManifest) mirrorError(err error) error {
+	if err == nil || m.Mirror == "" {
+		return err
+	}
+	return fmt.Errorf("%s (using dependency mirror %s)", err.Error(), m.Mirror)
+}
+
+func redactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	redacted := *u
+	redacted.User = url.UserPassword("-redacted-", "-redacted-")
+	return redacted.String()
+}
+
+func (m *StackManifest) AllDependencyVersions(depName string) []string {
+	var versions []string
+	for version, stacks := range m.DependencyStacks(depName) {
+		for _, stack := range stacks {
+			if stack == m.Stack {
+				versions = append(versions, version)
+				break
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// DefaultVersion resolves the manifest's default version of the dependency,
+// such as 1.8.x, against the versions published for the stack only, so an
+// older patch is picked when the stack lacks the newest one.
+func (m *StackManifest) DefaultVersion(depName string) (libbuildpack.Dependency, error) {
+	var defaults []string
+	for _, d := range m.DefaultVersions {
+		if d.Name == depName {
+			defaults = append(defaults, d.Version)
+		}
+	}
+
+	switch len(defaults) {
+	case 0:
+		return libbuildpack.Dependency{}, fmt.Errorf("no default version for %s", depName)
+	case 1:
+	default:
+		return libbuildpack.Dependency{}, fmt.Errorf("found %d default versions for %s", len(defaults), depName)
+	}
+
+	version, err := libbuildpack.FindMatchingVersion(defaults[0], m.AllDependencyVersions(depName))
+	if err != nil {
+		return libbuildpack.Dependency{}, fmt.Errorf("default version %s of %s is not available for stack %s: %s", defaults[0], depName, m.Stack, err.Error())
+	}
+	return libbuildpack.Dependency{Name: depName, Version: version}, nil
+}
+
+// DependencyStacks maps each version of the dependency to the stacks it is
+// published for.
+func (m *StackManifest) DependencyStacks(depName string) map[string][]string {
+	entries := m.AllEntries
+	if entries == nil {
+		entries = m.ManifestEntries
+	}
+
+	stacks := map[string][]string{}
+	for _, e := range entries {
+		if e.Dependency.Name == depName {
+			stacks[e.Dependency.Version] = append(stacks[e.Dependency.Version], e.CFStacks...)
+		}
+	}
+	return stacks
+}
+
 type Supplier struct {
-	Stager     Stager
-	Manifest   Manifest
-	Log        *libbuildpack.Logger
-	VendorTool string
-	GoVersion  string
-	Godep      godep.Godep
+	Stager           Stager
+	Manifest         Manifest
+	Command          Command
+	Log              *libbuildpack.Logger
+	BuildpackDir     string
+	VendorTool       string
+	VendorToolReason string
+	GoVersion        string
+	GoVersionSource  string
+	Godep            godep.Godep
+	PlanOnly         bool
 }
 
 func Run(gs *Supplier) error {
@@ -47,6 +246,25 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	if gs.PlanOnly {
+		if err := gs.SelectGoVersion(); err != nil {
+			gs.Log.Error("Unable to determine Go version to install: %s", err.Error())
+			return err
+		}
+
+		if err := gs.AdviseGoVersion(); err != nil {
+			gs.Log.Error("Unable to check Go version: %s", err.Error())
+			return err
+		}
+
+		gs.Log.Info("Staging plan requested: not installing Go %s or vendor tools", gs.GoVersion)
+		if err := gs.WriteConfigYml(); err != nil {
+			gs.Log.Error("Error writing config.yml: %s", err.Error())
+			return err
+		}
+		return nil
+	}
+
 	if err := gs.InstallVendorTools(); err != nil {
 		gs.Log.Error("Unable to install vendor tools", err.Error())
 		return err
@@ -57,6 +275,11 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	if err := gs.AdviseGoVersion(); err != nil {
+		gs.Log.Error("Unable to check Go version: %s", err.Error())
+		return err
+	}
+
 	if err := gs.InstallGo(); err != nil {
 		gs.Log.Error("Error installing Go: %s", err.Error())
 		return err
@@ -67,6 +290,11 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	if err := gs.InstallTools(); err != nil {
+		gs.Log.Error("Error installing Go tools: %s", err.Error())
+		return err
+	}
+
 	if err := gs.WriteConfigYml(); err != nil {
 		gs.Log.Error("Error writing config.yml: %s", err.Error())
 		return err
@@ -76,8 +304,6 @@ func Run(gs *Supplier) error {
 }
 
 func (gs *Supplier) SelectVendorTool() error {
-	godepsJSONFile := filepath.Join(gs.Stager.BuildDir(), "Godeps", "Godeps.json")
-
 	godirFile := filepath.Join(gs.Stager.BuildDir(), ".godir")
 	isGodir, err := libbuildpack.FileExists(godirFile)
 	if err != nil {
@@ -97,49 +323,94 @@ func (gs *Supplier) SelectVendorTool() error {
 		return errors.New("gb unsupported")
 	}
 
-	isGodep, err := libbuildpack.FileExists(godepsJSONFile)
-	if err != nil {
-		return err
-	}
-	if isGodep {
-		gs.Log.BeginStep("Checking Godeps/Godeps.json file")
-
-		err = libbuildpack.NewJSON().Load(filepath.Join(gs.Stager.BuildDir(), "Godeps", "Godeps.json"), &gs.Godep)
+	var candidates []vendorManifest
+	for _, m := range vendorManifests {
+		exists, err := libbuildpack.FileExists(filepath.Join(gs.Stager.BuildDir(), m.file))
 		if err != nil {
-			gs.Log.Error("Bad Godeps/Godeps.json file")
 			return err
 		}
+		if exists {
+			candidates = append(candidates, m)
+		}
+	}
 
-		gs.Godep.WorkspaceExists, err = libbuildpack.FileExists(filepath.Join(gs.Stager.BuildDir(), "Godeps", "_workspace", "src"))
-		if err != nil {
+	if tool := os.Getenv("GO_VENDOR_TOOL"); tool != "" {
+		if err := gs.forceVendorTool(tool, candidates); err != nil {
 			return err
 		}
+	} else if len(candidates) == 0 {
+		gs.VendorTool = "go_nativevendoring"
+		gs.VendorToolReason = "no Godeps/Godeps.json, glide.yaml or Gopkg.toml"
+	} else {
+		gs.VendorTool = candidates[0].tool
+		gs.VendorToolReason = "found " + candidates[0].file
 
-		gs.VendorTool = "godep"
-		return nil
+		if len(candidates) > 1 {
+			var found []string
+			for _, c := range candidates {
+				found = append(found, fmt.Sprintf("%s: %s", c.file, c.effect))
+			}
+			gs.Log.Warning("%s", warnings.MultipleVendorManifests(candidates[0].file, found))
+		}
 	}
 
-	glideFile := filepath.Join(gs.Stager.BuildDir(), "glide.yaml")
-	isGlide, err := libbuildpack.FileExists(glideFile)
-	if err != nil {
-		return err
+	if gs.VendorTool == "godep" {
+		return gs.loadGodep()
 	}
-	if isGlide {
-		gs.VendorTool = "glide"
+	return nil
+}
+
+// vendorManifests are the files that select a vendor tool, in order of
+// precedence.
+var vendorManifests = []vendorManifest{
+	{"godep", filepath.Join("Godeps", "Godeps.json"), "build with 'godep go install', using Godeps/_workspace if present"},
+	{"glide", "glide.yaml", "run 'glide install' unless vendor/ is already populated"},
+	{"dep", "Gopkg.toml", "run 'dep ensure' unless vendor/ is already populated"},
+}
+
+type vendorManifest struct {
+	tool   string
+	file   string
+	effect string
+}
+
+// forceVendorTool applies $GO_VENDOR_TOOL, for apps with more than one
+// dependency manifest, such as during a migration from glide to dep.
+func (gs *Supplier) forceVendorTool(tool string, candidates []vendorManifest) error {
+	if tool == "go_nativevendoring" {
+		gs.VendorTool = tool
+		gs.VendorToolReason = "$GO_VENDOR_TOOL is go_nativevendoring"
 		return nil
 	}
 
-	depFile := filepath.Join(gs.Stager.BuildDir(), "Gopkg.toml")
-	isDep, err := libbuildpack.FileExists(depFile)
+	for _, m := range vendorManifests {
+		if m.tool != tool {
+			continue
+		}
+		for _, c := range candidates {
+			if c.tool == tool {
+				gs.VendorTool = tool
+				gs.VendorToolReason = "$GO_VENDOR_TOOL is " + tool
+				return nil
+			}
+		}
+		return fmt.Errorf("$GO_VENDOR_TOOL is %s, but the app has no %s", tool, m.file)
+	}
+
+	return fmt.Errorf("$GO_VENDOR_TOOL must be one of godep, glide, dep or go_nativevendoring, not %s", tool)
+}
+
+func (gs *Supplier) loadGodep() error {
+	gs.Log.BeginStep("Checking Godeps/Godeps.json file")
+
+	err := libbuildpack.NewJSON().Load(filepath.Join(gs.Stager.BuildDir(), "Godeps", "Godeps.json"), &gs.Godep)
 	if err != nil {
+		gs.Log.Error("Bad Godeps/Godeps.json file")
 		return err
 	}
-	if isDep {
-		gs.VendorTool = "dep"
-		return nil
-	}
-	gs.VendorTool = "go_nativevendoring"
-	return nil
+
+	gs.Godep.WorkspaceExists, err = libbuildpack.FileExists(filepath.Join(gs.Stager.BuildDir(), "Godeps", "_workspace", "src"))
+	return err
 }
 
 func (gs *Supplier) WriteGoRootToProfileD() error {
@@ -150,10 +421,17 @@ func (gs *Supplier) WriteGoRootToProfileD() error {
 	return nil
 }
 
+// InstallVendorTools installs godep, glide and dep, skipping any the manifest
+// does not publish for the cell's architecture.
 func (gs *Supplier) InstallVendorTools() error {
 	tools := []string{"godep", "glide", "dep"}
 
 	for _, tool := range tools {
+		if len(gs.Manifest.AllDependencyVersions(tool)) == 0 {
+			gs.Log.Info("Skipping %s: not available for %s", tool, platform.HostArch())
+			continue
+		}
+
 		installDir := filepath.Join(gs.Stager.DepDir(), tool)
 		if err := gs.Manifest.InstallOnlyVersion(tool, installDir); err != nil {
 			return err
@@ -169,12 +447,14 @@ func (gs *Supplier) InstallVendorTools() error {
 
 func (gs *Supplier) SelectGoVersion() error {
 	goVersion := os.Getenv("GOVERSION")
+	source := "$GOVERSION"
 
 	if gs.VendorTool == "godep" {
 		if goVersion != "" {
 			gs.Log.Warning(warnings.GoVersionOverride(goVersion))
 		} else {
 			goVersion = gs.Godep.GoVersion
+			source = "Godeps/Godeps.json"
 		}
 	} else {
 		if goVersion == "" {
@@ -183,6 +463,7 @@ func (gs *Supplier) SelectGoVersion() error {
 				return err
 			}
 			goVersion = fmt.Sprintf("go%s", defaultGo.Version)
+			source = "buildpack default"
 		}
 	}
 
@@ -192,14 +473,131 @@ func (gs *Supplier) SelectGoVersion() error {
 	}
 
 	gs.GoVersion = parsed
+	gs.GoVersionSource = fmt.Sprintf("%s (%s)", source, goVersion)
+	return nil
+}
+
+// AdviseGoVersion compares the selected Go version with the newest patch of
+// its minor version, the manifest's end of life dates and Go's release policy,
+// which supports the two latest minor versions, and prints one recommendation.
+// With $GO_AUTO_FLOAT_PATCH=true an exact pin is first raised to the newest
+// patch.
+func (gs *Supplier) AdviseGoVersion() error {
+	versions := gs.Manifest.AllDependencyVersions("go")
+
+	current, err := semver.NewVersion(gs.GoVersion)
+	if err != nil {
+		return nil
+	}
+
+	latestPatch, err := libbuildpack.FindMatchingVersion(fmt.Sprintf("%d.%d.x", current.Major(), current.Minor()), versions)
+	if err != nil {
+		return err
+	}
+
+	if latestPatch != gs.GoVersion && os.Getenv("GO_AUTO_FLOAT_PATCH") == "true" {
+		gs.Log.Info("Using go %s instead of go %s, $GO_AUTO_FLOAT_PATCH is set", latestPatch, gs.GoVersion)
+		gs.GoVersionSource += fmt.Sprintf(", floated to %s by $GO_AUTO_FLOAT_PATCH", latestPatch)
+		gs.GoVersion = latestPatch
+		current = semver.MustParse(latestPatch)
+	}
+
+	var findings []string
+	if latestPatch != gs.GoVersion {
+		findings = append(findings, fmt.Sprintf("go %s is available with fixes for go %s", latestPatch, gs.GoVersion))
+	}
+
+	for _, d := range gs.Manifest.DependencyDeprecations("go") {
+		constraint, err := semver.NewConstraint(d.VersionLine)
+		if err != nil || !constraint.Check(current) {
+			continue
+		}
+		eol, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			return err
+		}
+		if eol.Before(time.Now()) {
+			findings = append(findings, fmt.Sprintf("go %s reached end of life on %s, see %s", d.VersionLine, d.Date, d.Link))
+		} else if eol.Before(time.Now().Add(eolNoticePeriod)) {
+			findings = append(findings, fmt.Sprintf("go %s reaches end of life on %s, see %s", d.VersionLine, d.Date, d.Link))
+		}
+	}
+
+	minors := supportedMinors(versions)
+	supported := false
+	for _, minor := range minors {
+		if minor == fmt.Sprintf("%d.%d", current.Major(), current.Minor()) {
+			supported = true
+		}
+	}
+	if !supported && len(minors) > 0 {
+		findings = append(findings, fmt.Sprintf("the Go team only supports the two latest minor versions, go %s", strings.Join(minors, " and go ")))
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	recommended := latestPatch
+	if !supported && len(minors) > 0 {
+		if recommended, err = libbuildpack.FindMatchingVersion(minors[0]+".x", versions); err != nil {
+			return err
+		}
+	}
+
+	gs.Log.Warning("%s", warnings.GoVersionAdvice(gs.GoVersion, recommended, findings, gs.VendorTool == "godep"))
 	return nil
 }
 
+// eolNoticePeriod is how far ahead AdviseGoVersion mentions an upcoming end
+// of life date.
+const eolNoticePeriod = 90 * 24 * time.Hour
+
+// supportedMinors returns the two latest minor versions in versions, newest
+// first.
+func supportedMinors(versions []string) []string {
+	var minors []*semver.Version
+	seen := map[string]bool{}
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		minor := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		if !seen[minor] {
+			seen[minor] = true
+			minors = append(minors, semver.MustParse(minor+".0"))
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Collection(minors)))
+
+	var supported []string
+	for i := 0; i < len(minors) && i < 2; i++ {
+		supported = append(supported, fmt.Sprintf("%d.%d", minors[i].Major(), minors[i].Minor()))
+	}
+	return supported
+}
+
 func (gs *Supplier) InstallGo() error {
 	goInstallDir := filepath.Join(gs.Stager.DepDir(), "go"+gs.GoVersion)
 
 	dep := libbuildpack.Dependency{Name: "go", Version: gs.GoVersion}
-	if err := gs.Manifest.InstallDependency(dep, goInstallDir); err != nil {
+	keyring := filepath.Join(gs.BuildpackDir, "signing", "go-keyring.gpg")
+	required := os.Getenv("GO_REQUIRE_SIGNED_TOOLCHAIN") == "true"
+
+	hasKeyring, err := libbuildpack.FileExists(keyring)
+	if err != nil {
+		return err
+	}
+
+	if !hasKeyring {
+		if required {
+			return fmt.Errorf("$GO_REQUIRE_SIGNED_TOOLCHAIN is set, but the buildpack has no keyring at signing/go-keyring.gpg")
+		}
+		keyring = ""
+	}
+
+	if err := gs.installGoArchive(dep, keyring, goInstallDir, required); err != nil {
 		return err
 	}
 
@@ -210,10 +608,139 @@ func (gs *Supplier) InstallGo() error {
 	return gs.Stager.WriteEnvFile("GOROOT", filepath.Join(goInstallDir, "go"))
 }
 
+// installGoArchive fetches and extracts the toolchain itself rather than
+// through Manifest.InstallDependency, whose own newer patch and end of life
+// warnings would repeat AdviseGoVersion's advice in other words. Given a
+// keyring, it first checks the archive against the detached signature in
+// the buildpack's signing/ directory. gpgv only reads the local keyring, so
+// this works offline.
+func (gs *Supplier) installGoArchive(dep libbuildpack.Dependency, keyring, goInstallDir string, required bool) error {
+	gs.Log.BeginStep("Installing %s %s", dep.Name, dep.Version)
+
+	tmpDir, err := ioutil.TempDir("", "gobuildpack.go")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive := filepath.Join(tmpDir, "go.tar.gz")
+	if err := gs.Manifest.FetchDependency(dep, archive); err != nil {
+		return err
+	}
+
+	if keyring != "" {
+		if err := gs.VerifyGoSignature(archive, keyring); err != nil {
+			if required {
+				return err
+			}
+			gs.Log.Warning("%s", warnings.UnverifiedGoToolchain(dep.Version, err.Error()))
+		} else {
+			gs.Log.Info("Verified signature of go %s", dep.Version)
+		}
+	}
+
+	if err := os.MkdirAll(goInstallDir, 0755); err != nil {
+		return err
+	}
+	return libbuildpack.ExtractTarGz(archive, goInstallDir)
+}
+
+// VerifyGoSignature runs gpgv on archive with the signature the buildpack
+// ships for the selected toolchain (go1.9.2.linux-amd64.tar.gz.asc). The
+// archive is the one manifest.yml points at, which need not be byte for byte
+// the go.dev download, so the signature must be made over that archive.
+func (gs *Supplier) VerifyGoSignature(archive, keyring string) error {
+	signature := filepath.Join(gs.BuildpackDir, "signing", fmt.Sprintf("go%s.linux-%s.tar.gz.asc", gs.GoVersion, platform.HostArch()))
+	exists, err := libbuildpack.FileExists(signature)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("the buildpack has no signature for go %s at signing/%s", gs.GoVersion, filepath.Base(signature))
+	}
+
+	output := new(bytes.Buffer)
+	if err := gs.Command.Execute("", output, output, "gpgv", "--keyring", keyring, signature, archive); err != nil {
+		return fmt.Errorf("signature of go %s does not verify: %s", gs.GoVersion, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// InstallTools builds the packages in $GO_TOOLS_PACKAGE_SPEC into
+// <depDir>/bin, which later buildpacks and the running app have on their
+// PATH. Entries are packages in the app ("./tools/gen") or packages vendored
+// by it ("github.com/org/tool/cmd/tool").
+func (gs *Supplier) InstallTools() error {
+	spec := strings.Fields(os.Getenv("GO_TOOLS_PACKAGE_SPEC"))
+	if len(spec) == 0 {
+		return nil
+	}
+
+	importPath, err := gs.appImportPath()
+	if err != nil {
+		return err
+	}
+
+	gs.Log.BeginStep("Installing Go tools: %s", strings.Join(spec, " "))
+
+	tmpDir, err := ioutil.TempDir("", "gobuildpack.tools")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	appDir := filepath.Join(tmpDir, "src", importPath)
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return err
+	}
+	if err := libbuildpack.CopyDirectory(gs.Stager.BuildDir(), appDir); err != nil {
+		return err
+	}
+
+	var packages []string
+	for _, pkg := range spec {
+		vendored, err := libbuildpack.FileExists(filepath.Join(appDir, "vendor", pkg))
+		if err != nil {
+			return err
+		}
+		if vendored && !strings.HasPrefix(pkg, ".") {
+			pkg = "./" + path.Join("vendor", pkg)
+		}
+		packages = append(packages, pkg)
+	}
+
+	binDir := filepath.Join(gs.Stager.DepDir(), "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	goRoot := filepath.Join(gs.Stager.DepDir(), "go"+gs.GoVersion, "go")
+	for name, value := range map[string]string{"GOROOT": goRoot, "GOPATH": tmpDir, "GOBIN": binDir} {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+
+	args := append([]string{"install", "-tags", "cloudfoundry"}, packages...)
+	if err := gs.Command.Execute(appDir, gs.Log.Output(), gs.Log.Output(), filepath.Join(goRoot, "bin", "go"), args...); err != nil {
+		return err
+	}
+
+	var tools []string
+	for _, pkg := range packages {
+		tools = append(tools, path.Base(pkg))
+	}
+	gs.Log.Info("Installed %s to %s", strings.Join(tools, ", "), binDir)
+
+	return gs.Stager.WriteEnvFile("GO_TOOLS", strings.Join(tools, " "))
+}
+
 func (gs *Supplier) WriteConfigYml() error {
 	config := map[string]string{
-		"GoVersion":  gs.GoVersion,
-		"VendorTool": gs.VendorTool,
+		"GoVersion":        gs.GoVersion,
+		"GoVersionSource":  gs.GoVersionSource,
+		"VendorTool":       gs.VendorTool,
+		"VendorToolReason": gs.VendorToolReason,
 	}
 
 	if gs.VendorTool == "godep" {
@@ -228,6 +755,34 @@ func (gs *Supplier) WriteConfigYml() error {
 	return gs.Stager.WriteConfigYml(config)
 }
 
+// appImportPath is where the app is placed in GOPATH to build tools, so that
+// its own and its vendored packages resolve.
+func (gs *Supplier) appImportPath() (string, error) {
+	switch {
+	case gs.VendorTool == "godep":
+		return gs.Godep.ImportPath, nil
+	case os.Getenv("GOPACKAGENAME") != "":
+		return os.Getenv("GOPACKAGENAME"), nil
+	case gs.VendorTool == "glide":
+		glide := struct {
+			Package string `yaml:"package"`
+		}{}
+		if err := libbuildpack.NewYAML().Load(filepath.Join(gs.Stager.BuildDir(), "glide.yaml"), &glide); err != nil {
+			return "", err
+		}
+		return glide.Package, nil
+	}
+
+	importPath, _, err := importpath.Infer(gs.Stager.BuildDir())
+	if err != nil {
+		return "", err
+	}
+	if importPath != "" {
+		return importPath, nil
+	}
+	return "", errors.New("set $GOPACKAGENAME to build $GO_TOOLS_PACKAGE_SPEC")
+}
+
 func (gs *Supplier) parseGoVersion(partialGoVersion string) (string, error) {
 	existingVersions := gs.Manifest.AllDependencyVersions("go")
 
@@ -239,6 +794,16 @@ func (gs *Supplier) parseGoVersion(partialGoVersion string) (string, error) {
 
 	expandedVer, err := libbuildpack.FindMatchingVersion(strippedGoVersion, existingVersions)
 	if err != nil {
+		stacks := gs.Manifest.DependencyStacks("go")
+		var allVersions []string
+		for version := range stacks {
+			allVersions = append(allVersions, version)
+		}
+
+		if otherVer, otherErr := libbuildpack.FindMatchingVersion(strippedGoVersion, allVersions); otherErr == nil {
+			gs.Log.Error("%s", warnings.GoVersionStackError(otherVer, os.Getenv("CF_STACK"), stacks[otherVer]))
+			return "", fmt.Errorf("go %s is not available for stack %s", otherVer, os.Getenv("CF_STACK"))
+		}
 		return "", err
 	}
 
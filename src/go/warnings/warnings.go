@@ -42,7 +42,8 @@ For support please file an issue: https://github.com/cloudfoundry/go-buildpack/i
 
 func NoGOPACKAGENAMEerror() string {
 	errorMessage := `To use go native vendoring set the $GOPACKAGENAME
-environment variable to your app's package name`
+environment variable to your app's package name, or declare it with an
+import comment in your main package: package main // import "<import path>"`
 
 	return errorMessage
 }
@@ -67,3 +68,222 @@ with go 1.6 this environment variable must unset or set to 1.`
 
 	return errorMessage
 }
+
+func DeniedLicenseError(offenders []string) string {
+	errorMessage := `Vendored dependencies use licenses denied by $GO_LICENSE_DENYLIST:
+    %s
+
+Remove these packages or run:
+    cf unset-env <app> GO_LICENSE_DENYLIST`
+
+	return fmt.Sprintf(errorMessage, strings.Join(offenders, "\n    "))
+}
+
+func VulnerableDependenciesError(threshold string, advisories []string) string {
+	errorMessage := `Dependencies match advisories at or above $GO_ADVISORY_FAIL_SEVERITY (%s):
+    %s
+
+Upgrade the affected dependencies or run:
+    cf unset-env <app> GO_ADVISORY_FAIL_SEVERITY`
+
+	return fmt.Sprintf(errorMessage, threshold, strings.Join(advisories, "\n    "))
+}
+
+func GoVersionStackError(goVersion, stack string, stacks []string) string {
+	errorMessage := `Go %s is not available for the %s stack.
+It is available for: %s
+
+Push the app to one of those stacks, or pick a version this stack supports with:
+    cf set-env <app> GOVERSION <version>`
+
+	return fmt.Sprintf(errorMessage, goVersion, stack, strings.Join(stacks, ", "))
+}
+
+func GodepsWorkspaceMigrated(importPath string, moved, kept, rewritten []string) string {
+	message := `Migrated Godeps/_workspace/src to vendor/ for this build only.
+Moved into vendor/:
+    %s
+Kept the existing vendor/ copies of:
+    %s
+Rewrote Godeps/_workspace imports in:
+    %s
+
+To commit the migration, from your app directory run:
+    for pkg in %s; do
+        mkdir -p vendor/$pkg && rsync -a --ignore-existing --exclude='*/' Godeps/_workspace/src/$pkg/ vendor/$pkg/
+    done
+    rm -rf Godeps/_workspace%s
+then commit %s, and run:
+    cf unset-env <app> GO_MIGRATE_GODEPS_WORKSPACE`
+
+	none := func(list []string) string {
+		if len(list) == 0 {
+			return "(none)"
+		}
+		return strings.Join(list, "\n    ")
+	}
+
+	rewrite, commit := "", "vendor/ and Godeps/"
+	if len(rewritten) != 0 {
+		rewrite = fmt.Sprintf("\n    grep -rl --include=*.go '%s/Godeps/_workspace/src/' . | xargs sed -i 's|%s/Godeps/_workspace/src/||g'", importPath, importPath)
+		commit = "vendor/, Godeps/ and the rewritten files"
+	}
+
+	return fmt.Sprintf(message, none(moved), none(kept), none(rewritten), strings.Join(moved, " "), rewrite, commit)
+}
+
+func UnverifiedGoToolchain(goVersion, reason string) string {
+	warning := `Installing go %s without verifying its signature:
+    %s
+
+Ask your operator to add the signature to the buildpack's signing/ directory.
+Operators can make unverified toolchains fail staging by setting
+$GO_REQUIRE_SIGNED_TOOLCHAIN=true in the staging environment variable group.`
+
+	return fmt.Sprintf(warning, goVersion, reason)
+}
+
+func GoVersionAdvice(goVersion, recommended string, findings []string, godep bool) string {
+	warning := `Consider upgrading from go %s:
+    %s
+
+Recommended version: go %s
+%s`
+
+	howTo := fmt.Sprintf(`To use it, set "GoVersion": "go%s" in Godeps/Godeps.json`, recommended)
+	if !godep {
+		howTo = fmt.Sprintf(`To use it, run:
+    cf set-env <app> GOVERSION go%s`, recommended)
+	}
+	if strings.HasPrefix(recommended+".", majorMinor(goVersion)+".") {
+		howTo += `
+or always use the newest patch of your Go version with:
+    cf set-env <app> GO_AUTO_FLOAT_PATCH true`
+	}
+
+	return fmt.Sprintf(warning, goVersion, strings.Join(findings, "\n    "), recommended, howTo)
+}
+
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func MissingImportsError(vendorTool string, missing []string) string {
+	errorMessage := `These imports are not in vendor/, Godeps/_workspace or the app:
+    %s
+
+%s
+To compile anyway and see the errors from go install, run:
+    cf set-env <app> GO_SKIP_IMPORT_CHECK true`
+
+	fix := map[string]string{
+		"godep": "Run 'godep save ./...' and commit the result.",
+		"glide": "Add them to glide.yaml and run 'glide install', or commit vendor/.",
+		"dep":   "Add them to Gopkg.toml and run 'dep ensure', or commit vendor/.",
+	}[vendorTool]
+	if fix == "" {
+		fix = "Copy them into vendor/ and push again."
+	}
+
+	return fmt.Sprintf(errorMessage, strings.Join(missing, "\n    "), fix+"\n")
+}
+
+func ShadowedVendorPackages(shadowed []string) string {
+	warning := `Packages are vendored more than once, and the nested copies hide the outer ones:
+    %s
+
+Each copy compiles to distinct types, which often breaks type assertions and
+shared state. Remove the nested vendor/ directories, for example with
+'dep prune' or 'glide install --strip-vendor'.`
+
+	return fmt.Sprintf(warning, strings.Join(shadowed, "\n    "))
+}
+
+func AmbiguousImportPathError(source string, candidates []string) string {
+	errorMessage := `$GOPACKAGENAME is not set, and the %s name more than one import path:
+    %s
+
+Set the app's import path with:
+    cf set-env <app> GOPACKAGENAME <import path>`
+
+	return fmt.Sprintf(errorMessage, source, strings.Join(candidates, "\n    "))
+}
+
+func MultipleVendorManifests(used string, found []string) string {
+	warning := `Found more than one dependency manifest, using %s:
+    %s
+
+To use a different vendor tool, run:
+    cf set-env <app> GO_VENDOR_TOOL <godep|glide|dep|go_nativevendoring>
+or remove the manifests you no longer use.`
+
+	return fmt.Sprintf(warning, used, strings.Join(found, "\n    "))
+}
+
+func MissingPackageHint(packages []string, vendorTool string) string {
+	hint := `The build could not find these packages:
+    %s
+
+%s`
+
+	fix := map[string]string{
+		"godep": "Run 'godep save ./...' so they are saved to Godeps/, and push again.",
+		"glide": "Add them to glide.yaml, run 'glide up' and push again.",
+		"dep":   "Run 'dep ensure' so Gopkg.lock and vendor/ include them, and push again.",
+	}[vendorTool]
+	if fix == "" {
+		fix = `Copy them into vendor/ and push again. If they are packages of your app,
+check that $GOPACKAGENAME is your app's import path.`
+	}
+
+	return fmt.Sprintf(hint, strings.Join(packages, "\n    "), fix)
+}
+
+func ImportCycleHint(cycle []string) string {
+	hint := `Your packages import each other in a cycle, which Go does not allow:
+    %s
+
+Move the code both packages need into a third package that neither imports.`
+
+	return fmt.Sprintf(hint, strings.Join(cycle, "\n    imports "))
+}
+
+func GoVersionTooOldHint(goVersion, needed, evidence string, godep bool) string {
+	hint := `Your code needs go %s or later, but go %s was used:
+    %s
+
+%s`
+
+	fix := fmt.Sprintf(`Set "GoVersion": "go%s" in Godeps/Godeps.json and push again.`, needed)
+	if !godep {
+		fix = fmt.Sprintf(`To use a newer Go, run:
+    cf set-env <app> GOVERSION go%s`, needed)
+	}
+
+	return fmt.Sprintf(hint, needed, goVersion, evidence, fix)
+}
+
+func PrivateRepoHint(host, vendorTool string) string {
+	hint := `Fetching a dependency from %s failed because it needs credentials.
+Staging cannot authenticate to private repositories.
+
+%s`
+
+	if host == "" {
+		host = "a private repository"
+	}
+
+	fix := "Vendor the private dependencies, commit vendor/ and push again."
+	switch vendorTool {
+	case "glide":
+		fix = "Run 'glide install' locally, commit vendor/ and push again."
+	case "dep":
+		fix = "Run 'dep ensure' locally, commit vendor/ and push again."
+	}
+
+	return fmt.Sprintf(hint, host, fix)
+}